@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ageLimit restricts listings to folders modified within a time window,
+// for --modified-within and --older-than. A zero field doesn't restrict.
+type ageLimit struct {
+	within time.Duration // modified at most this long ago
+	older  time.Duration // modified at least this long ago
+}
+
+func (a ageLimit) active() bool {
+	return a.within > 0 || a.older > 0
+}
+
+// allows reports whether a folder last modified at t is listed. A zero t,
+// for a folder whose info couldn't be read, is never within the window:
+// its age is unknown, so it's left out rather than guessed.
+func (a ageLimit) allows(t, now time.Time) bool {
+	if !a.active() {
+		return true
+	}
+	if t.IsZero() {
+		return false
+	}
+	age := now.Sub(t)
+	if a.within > 0 && age > a.within {
+		return false
+	}
+	return a.older == 0 || age >= a.older
+}
+
+// String describes the window for the status line, like "modified within 7d".
+func (a ageLimit) String() string {
+	switch {
+	case a.within > 0 && a.older > 0:
+		return "modified " + formatAge(a.older) + "-" + formatAge(a.within) + " ago"
+	case a.within > 0:
+		return "modified within " + formatAge(a.within)
+	case a.older > 0:
+		return "older than " + formatAge(a.older)
+	}
+	return ""
+}
+
+// ageUnits are the suffixes parseAge accepts beyond Go's duration units.
+var ageUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// parseAge parses an age like 7d, 2w or 24h. Days and weeks are added to
+// the units time.ParseDuration knows.
+func parseAge(s string) (time.Duration, error) {
+	if n := len(s); n > 1 {
+		if unit, ok := ageUnits[s[n-1:]]; ok {
+			if v, err := strconv.ParseFloat(s[:n-1], 64); err == nil && v > 0 {
+				return time.Duration(v * float64(unit)), nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age: %s (use a number with s, m, h, d or w, e.g. 7d)", s)
+	}
+	return d, nil
+}
+
+// formatAge formats d compactly, in whole days when it is some, like "14d".
+func formatAge(d time.Duration) string {
+	if d == 0 {
+		return "0"
+	}
+	if day := ageUnits["d"]; d%day == 0 {
+		return strconv.FormatInt(int64(d/day), 10) + "d"
+	}
+	// Drop the zero minutes and seconds of "24h0m0s"
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
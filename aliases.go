@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// aliasesPath returns the location of the start path aliases file, or ""
+// when there is no config directory.
+func aliasesPath() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "aliases")
+}
+
+// loadAliases reads the aliases file, which maps names to folders in the
+// same TOML subset as keys.toml:
+//
+//	work = "~/Dev/clients/acme"
+//	notes = "/srv/shared/notes"
+//
+// A missing file means no aliases.
+func loadAliases(path string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	if path == "" {
+		return aliases, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected name = \"path\"", path, lineNo)
+		}
+		values, err := parseTOMLStrings(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		dir := values[0]
+		if !filepath.IsAbs(dir) && dir != "~" && !strings.HasPrefix(dir, "~/") {
+			return nil, fmt.Errorf("%s:%d: %s must be an absolute path or start with ~/", path, lineNo, name)
+		}
+		aliases[name] = dir
+	}
+	return aliases, scanner.Err()
+}
+
+// expandAlias replaces a start path of the form @name, or @name/sub/dir,
+// with the aliased folder. Other paths are returned unchanged.
+func expandAlias(start string) (string, error) {
+	if !strings.HasPrefix(start, "@") {
+		return start, nil
+	}
+	name, rest, _ := strings.Cut(start[1:], "/")
+	aliases, err := loadAliases(aliasesPath())
+	if err != nil {
+		return "", err
+	}
+	dir, ok := aliases[name]
+	if !ok {
+		return "", fmt.Errorf("unknown alias @%s (define it in %s)", name, aliasesPath())
+	}
+	if rest != "" {
+		dir = filepath.Join(dir, rest)
+	}
+	return dir, nil
+}
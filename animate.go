@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// countFrame is how long each step of the --animate match count lasts.
+const countFrame = 40 * time.Millisecond
+
+// countMsg advances the --animate match count toward the real count.
+type countMsg struct{}
+
+func countTick() tea.Cmd {
+	return tea.Tick(countFrame, func(time.Time) tea.Msg { return countMsg{} })
+}
+
+// animateCount starts moving the shown match count when the real one has
+// changed. Ticks run only while the numbers differ, so an idle picker
+// doesn't redraw.
+func (m *model) animateCount() tea.Cmd {
+	if !m.opts.animate || m.counting || m.shownCount == m.matchCount() {
+		return nil
+	}
+	m.counting = true
+	return countTick()
+}
+
+// stepCount moves the shown count a third of the way to the real one, at
+// least by one, so big drops settle in a few frames.
+func (m *model) stepCount() tea.Cmd {
+	target := m.matchCount()
+	diff := target - m.shownCount
+	if diff == 0 {
+		m.counting = false
+		return nil
+	}
+	step := diff / 3
+	switch {
+	case step != 0:
+	case diff > 0:
+		step = 1
+	default:
+		step = -1
+	}
+	m.shownCount += step
+	return countTick()
+}
+
+// countText returns the match count shown after the filter with
+// --animate, or "" without it.
+func (m model) countText() string {
+	if !m.opts.animate {
+		return ""
+	}
+	return fmt.Sprintf("  %d matches", m.shownCount)
+}
+
+// countLabel is countText, highlighted while the count is changing.
+func (m model) countLabel() string {
+	label := m.countText()
+	if label == "" {
+		return ""
+	}
+	if m.counting {
+		return "\033[1;33m" + label + "\033[0m"
+	}
+	return "\033[90m" + label + "\033[0m"
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// crumb is one segment of the path header.
+type crumb struct {
+	name string // label shown in the header ("~", "/", "C:\\", or a folder name)
+	path string // absolute path the segment jumps to
+}
+
+// breadcrumbs splits root into header segments, starting at ~ when
+// abbreviate is set and root is inside the home directory, and at the
+// filesystem root (/ or a drive like C:\) otherwise.
+func breadcrumbs(root string, abbreviate bool) []crumb {
+	home, _ := os.UserHomeDir()
+	base := filepath.VolumeName(root) + string(filepath.Separator)
+	rest := root[len(base)-1:]
+	crumbs := []crumb{{name: base, path: base}}
+	if abbreviate && home != "" && !isRoot(home) && isWithin(root, home) {
+		base = home
+		rest = root[len(home):]
+		crumbs = []crumb{{name: "~", path: home}}
+	}
+
+	for _, part := range strings.Split(rest, string(filepath.Separator)) {
+		if part == "" {
+			continue
+		}
+		base = filepath.Join(base, part)
+		crumbs = append(crumbs, crumb{name: part, path: base})
+	}
+	return crumbs
+}
+
+// crumbs returns the header segments for the current folder. With a
+// --root (or .pf) boundary the header starts at the boundary folder.
+func (m model) crumbs() []crumb {
+	if b := m.boundary(); b != "" && !m.fullPath && isWithin(m.root, b) {
+		crumbs := []crumb{{name: filepath.Base(b), path: b}}
+		for _, c := range breadcrumbs(m.root, false) {
+			if c.path != b && isWithin(c.path, b) {
+				crumbs = append(crumbs, c)
+			}
+		}
+		return crumbs
+	}
+	return breadcrumbs(m.root, !m.fullPath)
+}
+
+// crumbSeparator returns the text shown after segment c. Breadcrumb mode
+// spaces the segments out so the selection is easy to see.
+func (m model) crumbSeparator(c crumb) string {
+	sep := string(filepath.Separator)
+	if isRoot(c.path) {
+		// The root's name already ends in a separator
+		if m.crumbMode {
+			return " "
+		}
+		return ""
+	}
+	if m.crumbMode {
+		return " " + sep + " "
+	}
+	return sep
+}
+
+// elided stands for the segments left out of a header too wide for the
+// terminal.
+const elided = -1
+
+// shownCrumbs returns the indexes of the header segments that fit in the
+// terminal width, with elided marking where segments were left out. By
+// default the middle of the path goes, as in ~/…/app/src; with
+// --header-style left the start goes instead. The last segment and the
+// one selected in breadcrumb mode are always kept.
+func (m model) shownCrumbs(crumbs []crumb) []int {
+	shown := make([]int, len(crumbs))
+	for i := range crumbs {
+		shown[i] = i
+	}
+	if m.width <= 0 {
+		return shown
+	}
+	ellipsis := crumb{name: "…"}
+	width := func() int {
+		w := 0
+		if m.crumbMode && m.opts.noColor {
+			w = 2 // the brackets around the selected segment
+		}
+		for _, i := range shown {
+			c := ellipsis
+			if i != elided {
+				c = crumbs[i]
+			}
+			w += utf8.RuneCountInString(c.name) + utf8.RuneCountInString(m.crumbSeparator(c))
+		}
+		return w
+	}
+	// The first segment stays unless eliding from the left
+	first := 1
+	if m.opts.headerStyle == "left" {
+		first = 0
+	}
+	for width() > m.width-utf8.RuneCountInString(m.headerStats()) {
+		drop := -1
+		for pos := first; pos < len(shown)-1; pos++ {
+			i := shown[pos]
+			if i != elided && !(m.crumbMode && i == m.crumbCursor) {
+				drop = pos
+				break
+			}
+		}
+		if drop < 0 {
+			break
+		}
+		if drop > 0 && shown[drop-1] == elided {
+			shown = append(shown[:drop], shown[drop+1:]...)
+		} else {
+			shown[drop] = elided
+		}
+	}
+	return shown
+}
+
+// headerView renders the path header, highlighting the selected segment
+// while in breadcrumb mode.
+func (m model) headerView() string {
+	crumbs := m.crumbs()
+	shown := m.shownCrumbs(crumbs)
+	var b strings.Builder
+	for pos, i := range shown {
+		c := crumb{name: "…"}
+		if i != elided {
+			c = crumbs[i]
+		}
+		switch {
+		case m.crumbMode && i == m.crumbCursor && m.opts.noColor:
+			// Without reverse video the selected segment needs brackets
+			b.WriteString("[" + c.name + "]")
+		case m.crumbMode && i == m.crumbCursor:
+			b.WriteString("\033[1;7;34m" + c.name + "\033[0m")
+		default:
+			b.WriteString("\033[1;34m" + c.name + "\033[0m")
+		}
+		if sep := m.crumbSeparator(c); sep != "" && pos < len(shown)-1 {
+			b.WriteString("\033[34m" + sep + "\033[0m")
+		}
+	}
+	if stats := m.headerStats(); stats != "" {
+		b.WriteString("\033[90m" + stats + "\033[0m")
+	}
+	return b.String()
+}
+
+// headerStats returns the --stats note shown after the path, such as
+// " (24 folders, 1.2G)", or "" without --stats. The size, with --du, adds
+// up the listed folders once they've all been measured.
+func (m model) headerStats() string {
+	if !m.opts.stats {
+		return ""
+	}
+	n := len(m.entries) - m.pinned
+	text := fmt.Sprintf("%d folders", n)
+	if n == 1 {
+		text = "1 folder"
+	}
+	if m.opts.du && n > 0 {
+		if m.sizing || m.walking {
+			text += ", …"
+		} else {
+			var total dirSize
+			for _, it := range m.entries[m.pinned:] {
+				if it.size != nil {
+					total.bytes += it.size.bytes
+					total.partial = total.partial || it.size.partial
+				}
+			}
+			text += ", " + total.String()
+		}
+	}
+	return " (" + text + ")"
+}
+
+// crumbAt returns the index of the header segment at column x, or -1.
+func (m model) crumbAt(x int) int {
+	crumbs := m.crumbs()
+	col := 0
+	for _, i := range m.shownCrumbs(crumbs) {
+		c := crumb{name: "…"}
+		if i != elided {
+			c = crumbs[i]
+		}
+		width := utf8.RuneCountInString(c.name)
+		if x >= col && x < col+width {
+			return i
+		}
+		col += width + utf8.RuneCountInString(m.crumbSeparator(c))
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// clipboardMsg reports the outcome of copying text to the clipboard.
+type clipboardMsg struct {
+	text string
+	err  error
+}
+
+// clipboardCommands lists the commands that read text on stdin into the
+// system clipboard, in order of preference for the current platform.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	}
+	return [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+}
+
+// copyText puts text on the clipboard with the first command available.
+func copyText(text string) tea.Cmd {
+	return func() tea.Msg {
+		for _, args := range clipboardCommands() {
+			if _, err := exec.LookPath(args[0]); err != nil {
+				continue
+			}
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdin = strings.NewReader(text)
+			return clipboardMsg{text: text, err: cmd.Run()}
+		}
+		return clipboardMsg{text: text, err: errors.New("no clipboard command found")}
+	}
+}
+
+// cdCommand returns a cd command for path that is safe to paste into a
+// POSIX shell: the path is single-quoted, and any quote inside it closes
+// the quoting, adds an escaped quote and reopens it.
+func cdCommand(path string) string {
+	return "cd '" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
+
+// clipboardNotice describes msg for the status line.
+func clipboardNotice(msg clipboardMsg) string {
+	if msg.err != nil {
+		return "\033[31mCouldn't copy to the clipboard: " + msg.err.Error() + "\033[0m"
+	}
+	return "\033[90mCopied " + msg.text + "\033[0m"
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// flagSpec describes a command-line option for shell completion.
+type flagSpec struct {
+	name   string   // without the leading --
+	desc   string   // short description for zsh and fish
+	arg    bool     // takes a value
+	values []string // fixed choices for the value, if any
+	dirs   bool     // the value is a directory
+	files  bool     // the value may be a file path
+}
+
+// completionFlags lists the options parseArgs accepts.
+var completionFlags = []flagSpec{
+	{name: "help", desc: "Show help"},
+	{name: "install", desc: "Install the shell function"},
+	{name: "max-results", desc: "Show at most N matches", arg: true},
+	{name: "mouse", desc: "Click path segments in the header"},
+	{name: "height", desc: "Use at most N terminal rows", arg: true},
+	{name: "scroll-margin", desc: "Rows kept visible around the cursor", arg: true},
+	{name: "query", desc: "Start with text in the filter", arg: true},
+	{name: "rank", desc: "How matches are ordered", arg: true, values: rankModeNames},
+	{name: "smart-case", desc: "Uppercase in the filter matches case"},
+	{name: "match", desc: "How filter words match names", arg: true, values: matcherNames},
+	{name: "sort", desc: "Initial sort mode", arg: true, values: sortModeNames},
+	{name: "no-sort", desc: "Keep folders in filesystem order"},
+	{name: "leaves", desc: "List only leaf folders, recursively"},
+	{name: "repos", desc: "List only git repositories, recursively"},
+	{name: "max-depth", desc: "How deep --repos looks", arg: true},
+	{name: "depth", desc: "List every folder down to N levels", arg: true},
+	{name: "symlink-loop", desc: "How walks treat symlinked folders", arg: true, values: linkModeNames},
+	{name: "manifest", desc: "List folders from a .pf-dirs file"},
+	{name: "enter-selects", desc: "Enter selects; right arrow opens"},
+	{name: "auto-descend", desc: "Open through single-subfolder chains"},
+	{name: "cursor-start", desc: "Where the cursor starts", arg: true, values: []string{cursorStub, cursorFirst}},
+	{name: "no-stub", desc: "Leave out the select-this-folder entry"},
+	{name: "keep-stub", desc: "Keep the select-this-folder entry while filtering"},
+	{name: "show-parent", desc: "Add a .. entry"},
+	{name: "wrap-siblings", desc: "Sibling keys wrap around"},
+	{name: "sticky-filter", desc: "Keep the filter when navigating"},
+	{name: "path-filter", desc: "Open folders by typing their path"},
+	{name: "save-history", desc: "Remember filters across sessions"},
+	{name: "tui", desc: "Where to draw the interface", arg: true, values: []string{"stdout", "stderr", "tty"}, files: true},
+	{name: "output", desc: "Where to write the selected path", arg: true, values: []string{"stdout", "stderr", "tty"}, files: true},
+	{name: "trailing-slash", desc: "Print the path with a trailing /"},
+	{name: "no-trailing-slash", desc: "Print the path without a trailing /"},
+	{name: "echo", desc: "Confirm the selection on stderr"},
+	{name: "multi", desc: "Mark and print several folders"},
+	{name: "loop", desc: "Keep running, printing each selection"},
+	{name: "preselect", desc: "Start with the folders in a file marked", arg: true, files: true},
+	{name: "socket", desc: "Send the selected path to a Unix socket", arg: true, files: true},
+	{name: "log-jumps", desc: "Record each selection with the time", arg: true, files: true},
+	{name: "append-to", desc: "Also append the selected path to a file", arg: true, files: true},
+	{name: "env-path", desc: "Print the home folder as $HOME"},
+	{name: "tilde-path", desc: "Print the home folder as ~"},
+	{name: "name-only", desc: "Print only the folder name"},
+	{name: "root", desc: "Stay inside this folder", arg: true, dirs: true},
+	{name: "read-timeout", desc: "Give up on slow folder reads after N seconds", arg: true},
+	{name: "du", desc: "Show folder sizes"},
+	{name: "stats", desc: "Show the folder count in the header"},
+	{name: "mounts", desc: "Mark mount points"},
+	{name: "long", desc: "Show permissions and owners"},
+	{name: "link-targets", desc: "Show where symlinked folders point"},
+	{name: "times", desc: "Show modification times"},
+	{name: "only", desc: "List only folders matching a glob", arg: true},
+	{name: "modified-within", desc: "List only folders modified recently", arg: true},
+	{name: "older-than", desc: "List only folders not modified recently", arg: true},
+	{name: "show-hidden", desc: "Always list these dot-folders", arg: true},
+	{name: "hidden-only", desc: "List only hidden folders"},
+	{name: "count-skipped", desc: "Count hidden and ignored folders"},
+	{name: "no-ignore", desc: "Show normally skipped folders"},
+	{name: "trash", desc: "Delete moves folders to the trash"},
+	{name: "allow-local-config", desc: "Read .pf files"},
+	{name: "timeout", desc: "Quit after N idle seconds", arg: true},
+	{name: "alt-screen", desc: "Use the alternate screen"},
+	{name: "animate", desc: "Animate the match count"},
+	{name: "bottom", desc: "Anchor the picker to the bottom"},
+	{name: "no-color", desc: "Draw plain text without colors"},
+	{name: "header-style", desc: "Where long paths are shortened", arg: true, values: []string{"middle", "left"}},
+	{name: "debug", desc: "Print worked-around errors on exit"},
+	{name: "verbose", desc: "Log events to the cache folder"},
+	{name: "dry-run", desc: "Print what --query would select"},
+	{name: "list", desc: "Print every folder --query matches"},
+	{name: "save-query", desc: "Remember the filter used to select"},
+	{name: "last-query", desc: "Print the remembered filter"},
+	{name: "print-config", desc: "Print the settings in effect"},
+	{name: "select-current", desc: "Print the resolved start path"},
+}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionScript returns the completion script for shell.
+func completionScript(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletion(), nil
+	case "zsh":
+		return zshCompletion(), nil
+	case "fish":
+		return fishCompletion(), nil
+	}
+	return "", fmt.Errorf("unknown shell: %s (use %s)", shell, strings.Join(completionShells, ", "))
+}
+
+func bashCompletion() string {
+	var b strings.Builder
+	var names []string
+	for _, f := range completionFlags {
+		names = append(names, "--"+f.name)
+	}
+	b.WriteString("# pf completion for bash\n")
+	b.WriteString("_pf() {\n")
+	b.WriteString("  local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("  case \"$prev\" in\n")
+	for _, f := range completionFlags {
+		if !f.arg {
+			continue
+		}
+		var gen string
+		switch {
+		case f.dirs:
+			gen = "compgen -d -- \"$cur\""
+		case len(f.values) > 0 && f.files:
+			gen = "compgen -W \"" + strings.Join(f.values, " ") + "\" -f -- \"$cur\""
+		case len(f.values) > 0:
+			gen = "compgen -W \"" + strings.Join(f.values, " ") + "\" -- \"$cur\""
+		case f.files:
+			gen = "compgen -f -- \"$cur\""
+		default:
+			gen = "true"
+		}
+		fmt.Fprintf(&b, "    --%s) COMPREPLY=($(%s)); return ;;\n", f.name, gen)
+	}
+	b.WriteString("  esac\n")
+	b.WriteString("  if [[ \"$cur\" == -* ]]; then\n")
+	b.WriteString("    COMPREPLY=($(compgen -W \"" + strings.Join(names, " ") + "\" -- \"$cur\"))\n")
+	b.WriteString("  else\n")
+	b.WriteString("    COMPREPLY=($(compgen -d -- \"$cur\"))\n")
+	b.WriteString("  fi\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -o filenames -F _pf pf\n")
+	return b.String()
+}
+
+func zshCompletion() string {
+	var b strings.Builder
+	b.WriteString("#compdef pf\n")
+	b.WriteString("# pf completion for zsh\n")
+	b.WriteString("_arguments \\\n")
+	for _, f := range completionFlags {
+		spec := "--" + f.name + "[" + f.desc + "]"
+		switch {
+		case f.dirs:
+			spec += ":dir:_files -/"
+		case len(f.values) > 0 && f.files:
+			spec += ":target:{_alternative \"targets:target:(" + strings.Join(f.values, " ") + ")\" \"files:file:_files\"}"
+		case len(f.values) > 0:
+			spec += ":value:(" + strings.Join(f.values, " ") + ")"
+		case f.files:
+			spec += ":file:_files"
+		case f.arg:
+			spec += ":value: "
+		}
+		fmt.Fprintf(&b, "  '%s' \\\n", spec)
+	}
+	b.WriteString("  '1:start path:_files -/'\n")
+	return b.String()
+}
+
+func fishCompletion() string {
+	var b strings.Builder
+	b.WriteString("# pf completion for fish\n")
+	b.WriteString("complete -c pf -f -a '(__fish_complete_directories)'\n")
+	for _, f := range completionFlags {
+		line := "complete -c pf -l " + f.name + " -d '" + f.desc + "'"
+		switch {
+		case f.dirs:
+			line += " -r -f -a '(__fish_complete_directories)'"
+		case len(f.values) > 0 && f.files:
+			line += " -r -F -a '" + strings.Join(f.values, " ") + "'"
+		case len(f.values) > 0:
+			line += " -x -a '" + strings.Join(f.values, " ") + "'"
+		case f.files:
+			line += " -r -F"
+		case f.arg:
+			line += " -x"
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
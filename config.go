@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// configSetting is an option shown by --print-config.
+type configSetting struct {
+	key string            // the flag's name, without the leading --
+	get func(options) any // the option's value
+}
+
+// configSettings lists the options --print-config reports, in the order
+// printUsage describes them.
+var configSettings = []configSetting{
+	{key: "start", get: func(o options) any { return o.start }},
+	{key: "max-results", get: func(o options) any { return o.maxResults }},
+	{key: "mouse", get: func(o options) any { return o.mouse }},
+	{key: "height", get: func(o options) any { return o.height }},
+	{key: "scroll-margin", get: func(o options) any { return o.scrollMargin }},
+	{key: "query", get: func(o options) any { return o.query }},
+	{key: "rank", get: func(o options) any { return o.rank }},
+	{key: "smart-case", get: func(o options) any { return o.smartCase }},
+	{key: "match", get: func(o options) any { return o.match }},
+	{key: "sort", get: func(o options) any { return o.sort }},
+	{key: "leaves", get: func(o options) any { return o.leaves }},
+	{key: "repos", get: func(o options) any { return o.repos }},
+	{key: "max-depth", get: func(o options) any { return o.maxDepth }},
+	{key: "depth", get: func(o options) any { return o.depth }},
+	{key: "symlink-loop", get: func(o options) any { return o.symlinkLoop }},
+	{key: "manifest", get: func(o options) any { return o.manifest }},
+	{key: "enter-selects", get: func(o options) any { return o.enterSelects }},
+	{key: "auto-descend", get: func(o options) any { return o.autoDescend }},
+	{key: "cursor-start", get: func(o options) any { return o.cursorStart }},
+	{key: "no-stub", get: func(o options) any { return o.noStub }},
+	{key: "keep-stub", get: func(o options) any { return o.keepStub }},
+	{key: "show-parent", get: func(o options) any { return o.showParent }},
+	{key: "wrap-siblings", get: func(o options) any { return o.wrapSiblings }},
+	{key: "sticky-filter", get: func(o options) any { return o.stickyFilter }},
+	{key: "path-filter", get: func(o options) any { return o.pathFilter }},
+	{key: "save-history", get: func(o options) any { return o.saveHistory }},
+	{key: "tui", get: func(o options) any { return o.tuiOutput }},
+	{key: "output", get: func(o options) any { return o.output }},
+	{key: "trailing-slash", get: func(o options) any { return o.trailingSlash }},
+	{key: "no-trailing-slash", get: func(o options) any { return o.noTrailingSlash }},
+	{key: "echo", get: func(o options) any { return o.echo }},
+	{key: "multi", get: func(o options) any { return o.multi }},
+	{key: "loop", get: func(o options) any { return o.loop }},
+	{key: "preselect", get: func(o options) any { return o.preselect }},
+	{key: "socket", get: func(o options) any { return o.socket }},
+	{key: "log-jumps", get: func(o options) any { return o.logJumps }},
+	{key: "append-to", get: func(o options) any { return o.appendTo }},
+	{key: "env-path", get: func(o options) any { return o.envPath }},
+	{key: "tilde-path", get: func(o options) any { return o.tildePath }},
+	{key: "name-only", get: func(o options) any { return o.nameOnly }},
+	{key: "root", get: func(o options) any { return o.boundary }},
+	{key: "read-timeout", get: func(o options) any { return o.readTimeout }},
+	{key: "du", get: func(o options) any { return o.du }},
+	{key: "stats", get: func(o options) any { return o.stats }},
+	{key: "mounts", get: func(o options) any { return o.mounts }},
+	{key: "long", get: func(o options) any { return o.long }},
+	{key: "link-targets", get: func(o options) any { return o.linkTargets }},
+	{key: "times", get: func(o options) any { return o.showTimes }},
+	{key: "only", get: func(o options) any { return o.only }},
+	{key: "modified-within", get: func(o options) any { return formatAge(o.age.within) }},
+	{key: "older-than", get: func(o options) any { return formatAge(o.age.older) }},
+	{key: "show-hidden", get: func(o options) any { return strings.Join(o.showHidden, ",") }},
+	{key: "hidden-only", get: func(o options) any { return o.hiddenOnly }},
+	{key: "count-skipped", get: func(o options) any { return o.countSkipped }},
+	{key: "no-ignore", get: func(o options) any { return o.noIgnore }},
+	{key: "trash", get: func(o options) any { return o.trash }},
+	{key: "allow-local-config", get: func(o options) any { return o.localConfig }},
+	{key: "animate", get: func(o options) any { return o.animate }},
+	{key: "timeout", get: func(o options) any { return o.timeout }},
+	{key: "alt-screen", get: func(o options) any { return o.altScreen }},
+	{key: "bottom", get: func(o options) any { return o.bottom }},
+	{key: "no-color", get: func(o options) any { return o.noColor }},
+	{key: "header-style", get: func(o options) any { return o.headerStyle }},
+	{key: "debug", get: func(o options) any { return o.debug }},
+	{key: "verbose", get: func(o options) any { return o.verbose }},
+	{key: "save-query", get: func(o options) any { return o.saveQuery }},
+}
+
+// configLine is one key=value line of --print-config output.
+type configLine struct {
+	key, value string
+	source     string // default, flag, env VAR, or the file it was read from
+}
+
+// setSource records where a setting came from when it wasn't a flag, such
+// as an environment variable or a file, for --print-config.
+func (o *options) setSource(key, source string) {
+	if o.sources == nil {
+		o.sources = make(map[string]string)
+	}
+	o.sources[key] = source
+}
+
+// resolveConfig returns the settings in effect for a session whose options
+// resolved to opts once the environment was applied. A setting main took
+// from elsewhere carries the source it recorded; any other that differs
+// from the default was set by a flag.
+func resolveConfig(opts options) []configLine {
+	defaults, _ := parseArgs(nil)
+
+	var lines []configLine
+	for _, s := range configSettings {
+		line := configLine{key: s.key, value: fmt.Sprint(s.get(opts)), source: "default"}
+		switch {
+		case opts.sources[s.key] != "":
+			line.source = opts.sources[s.key]
+		case line.value != fmt.Sprint(s.get(defaults)):
+			line.source = "flag"
+		}
+		lines = append(lines, line)
+	}
+
+	// What the start folder resolves to, and the .pf file covering it
+	start, err := resolveStart(opts.start)
+	if err != nil {
+		return append(lines, configLine{key: "start-error", value: err.Error()})
+	}
+	lines = append(lines, configLine{key: "start-path", value: start, source: "resolved"})
+	m := model{root: start, opts: opts, sort: opts.sort}
+	m.applyLocalConfig()
+	if m.local.path != "" {
+		lines = append(lines, configLine{key: "local-config", value: m.local.path, source: "found"})
+		if m.local.err != nil {
+			lines = append(lines, configLine{key: "local-config-error", value: m.local.err.Error(), source: m.local.path})
+		}
+		if m.local.hasSort {
+			lines = setConfig(lines, "sort", m.local.sort.String(), m.local.path)
+		}
+		if m.local.match != "" {
+			lines = setConfig(lines, "match", m.local.match, m.local.path)
+		}
+		if m.local.cursor != "" {
+			lines = setConfig(lines, "cursor-start", m.local.cursor, m.local.path)
+		}
+		if len(m.local.show) > 0 {
+			shown := append(slices.Clone(opts.showHidden), m.local.show...)
+			lines = setConfig(lines, "show-hidden", strings.Join(shown, ","), m.local.path)
+		}
+		if b := m.boundary(); b != opts.boundary {
+			lines = setConfig(lines, "root", b, m.local.path)
+		}
+	}
+
+	// The folders every listing leaves out besides hidden ones
+	ignore := configLine{key: "ignore", source: "default"}
+	if opts.noIgnore {
+		ignore.source = "flag"
+	} else {
+		patterns := append([]string{"node_modules", "vendor"}, m.local.ignore...)
+		ignore.value = strings.Join(patterns, ",")
+		if len(m.local.ignore) > 0 {
+			ignore.source = "default, " + m.local.path
+		}
+	}
+	lines = append(lines, ignore)
+
+	// Folders rename, archive and delete refuse to touch
+	path := protectedPath()
+	protected := configLine{key: "protected", value: "~,/", source: "default"}
+	dirs, err := loadProtected(path)
+	switch {
+	case err != nil:
+		protected = configLine{key: "protected-error", value: err.Error(), source: path}
+	case len(dirs) > 0:
+		protected.value += "," + strings.Join(dirs, ",")
+		protected.source = "default, " + path
+	}
+	lines = append(lines, protected)
+
+	// Key bindings that keys.toml changes
+	path = keymapPath()
+	keys, err := loadKeymap(path)
+	switch {
+	case err != nil:
+		lines = append(lines, configLine{key: "keys-error", value: err.Error(), source: path})
+	case path != "" && fileExists(path):
+		lines = append(lines, configLine{key: "keys", value: path, source: "found"})
+		var actions []string
+		for action := range defaultBindings {
+			actions = append(actions, action)
+		}
+		slices.Sort(actions)
+		for _, action := range actions {
+			if !slices.Equal(keys.keys[action], defaultBindings[action]) {
+				lines = append(lines, configLine{key: "key." + action, value: strings.Join(keys.keys[action], ","), source: path})
+			}
+		}
+	}
+	return lines
+}
+
+// setConfig replaces the value and source of the line for key.
+func setConfig(lines []configLine, key, value, source string) []configLine {
+	for i := range lines {
+		if lines[i].key == key {
+			lines[i].value, lines[i].source = value, source
+		}
+	}
+	return lines
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// printConfig writes the lines as key=value, each followed by a comment
+// naming its source, aligned so the sources line up.
+func printConfig(w io.Writer, lines []configLine) {
+	width := 0
+	for _, l := range lines {
+		width = max(width, len(l.key)+1+len(l.value))
+	}
+	for _, l := range lines {
+		text := l.key + "=" + l.value
+		if l.source == "" {
+			fmt.Fprintln(w, text)
+			continue
+		}
+		fmt.Fprintf(w, "%-*s  # %s\n", width, text, l.source)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// readGrace is how long reload waits for a --read-timeout read before
+// leaving it to finish in the background. Most folders are read well
+// within it, so they appear at once with the cursor placed as usual.
+const readGrace = 50 * time.Millisecond
+
+// listing is the result of reading a folder.
+type listing struct {
+	items   []item
+	skipped skipCounts
+	err     error
+}
+
+// readListing reads root's subfolders, marking mount points with --mounts,
+// reading symlink targets with --link-targets and permissions with --long.
+func readListing(root string, filter dirFilter, log *errorLog, opts options) listing {
+	start := time.Now()
+	items, skipped, err := loadDir(root, filter, log)
+	if opts.mounts {
+		markMounts(root, items)
+	}
+	if opts.linkTargets {
+		readLinks(items)
+	}
+	if opts.long {
+		readPerms(items)
+	}
+	logf("load", "path=%q folders=%d took=%s", root, len(items), since(start))
+	return listing{items, skipped, err}
+}
+
+// dirRead is a folder read running in the background, so a hanging
+// network mount can't freeze the picker. The goroutine can't be stopped;
+// an abandoned read finishes, or hangs, unobserved.
+type dirRead struct {
+	id       int
+	path     string
+	done     chan listing
+	deadline time.Time
+}
+
+// dirReadMsg delivers a background read, or reports that it timed out.
+type dirReadMsg struct {
+	id       int // read that finished; stale reads are ignored
+	listing  listing
+	timedOut bool
+}
+
+func startDirRead(id int, root string, filter dirFilter, log *errorLog, opts options, timeout time.Duration) dirRead {
+	r := dirRead{id: id, path: root, done: make(chan listing, 1), deadline: time.Now().Add(timeout)}
+	go func() { r.done <- readListing(root, filter, log, opts) }()
+	return r
+}
+
+// poll waits up to d for the read to finish.
+func (r dirRead) poll(d time.Duration) (listing, bool) {
+	select {
+	case l := <-r.done:
+		return l, true
+	case <-time.After(d):
+		return listing{}, false
+	}
+}
+
+// wait returns the command that delivers the read, or its timeout.
+func (r dirRead) wait() tea.Cmd {
+	return func() tea.Msg {
+		l, ok := r.poll(time.Until(r.deadline))
+		return dirReadMsg{id: r.id, listing: l, timedOut: !ok}
+	}
+}
+
+// seconds converts a --read-timeout value to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// timeoutError is logged when a read is abandoned.
+type timeoutError time.Duration
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("no response after %s", time.Duration(e))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// dirSize is the total size of the files below a folder, for --du.
+type dirSize struct {
+	bytes   int64
+	partial bool // part of the tree couldn't be read
+}
+
+// String formats the size compactly, like "1.2G" or "340M", with a ? when
+// some of the tree was unreadable.
+func (s dirSize) String() string {
+	text := humanSize(s.bytes)
+	if s.partial {
+		if s.bytes == 0 {
+			return "?"
+		}
+		text += "?"
+	}
+	return text
+}
+
+func humanSize(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n)
+	i := -1
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	if v < 10 {
+		return fmt.Sprintf("%.1f%c", v, units[i])
+	}
+	return fmt.Sprintf("%.0f%c", v, units[i])
+}
+
+// measure adds up the sizes of the files below dir. Symlinks inside the
+// tree aren't followed, so nothing is counted twice through a loop. It
+// gives up early, with a partial total, once cancel is closed.
+func measure(dir string, cancel chan struct{}) dirSize {
+	var size dirSize
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		select {
+		case <-cancel:
+			return filepath.SkipAll
+		default:
+		}
+		if err != nil {
+			size.partial = true
+			return nil
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				size.partial = true
+				return nil
+			}
+			size.bytes += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// sizeCache keeps measured sizes by path, so revisiting a folder during the
+// session doesn't measure it again.
+type sizeCache map[string]dirSize
+
+// sizeMsg delivers the size of one folder measured in the background.
+type sizeMsg struct {
+	id   int // sizer that measured it; stale sizers are ignored
+	path string
+	size dirSize
+	done bool // every folder has been measured
+}
+
+// sizer measures folders one at a time in the background.
+type sizer struct {
+	id      int
+	results chan sizeMsg
+	cancel  chan struct{}
+	total   int // folders to measure
+}
+
+func startSizer(id int, paths []string) sizer {
+	s := sizer{id: id, results: make(chan sizeMsg), cancel: make(chan struct{}), total: len(paths)}
+	go func() {
+		defer close(s.results)
+		for _, p := range paths {
+			size := measure(p, s.cancel)
+			select {
+			case s.results <- sizeMsg{id: id, path: p, size: size}:
+			case <-s.cancel:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// next waits for the next measured folder.
+func (s sizer) next() tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-s.results
+		if !ok {
+			return sizeMsg{id: s.id, done: true}
+		}
+		return msg
+	}
+}
+
+// stop cancels the sizer if it is still running.
+func (s sizer) stop() {
+	if s.cancel == nil {
+		return
+	}
+	select {
+	case <-s.cancel:
+	default:
+		close(s.cancel)
+	}
+}
+
+// startSizing fills in the sizes of the listed folders with --du: cached
+// ones at once, and the rest in the background.
+func (m *model) startSizing() tea.Cmd {
+	if !m.opts.du {
+		return nil
+	}
+	var todo []string
+	for i := m.pinned; i < len(m.entries); i++ {
+		if size, ok := m.sizes[m.entries[i].path]; ok {
+			m.entries[i].size = &size
+		} else {
+			todo = append(todo, m.entries[i].path)
+		}
+	}
+	if m.sort == sortSize {
+		m.resort()
+	} else {
+		m.rematch()
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+	m.sizer = startSizer(m.sizer.id+1, todo)
+	m.sizing = true
+	m.measured = 0
+	return tea.Batch(m.sizer.next(), m.startSpinner())
+}
+
+// setSize records a measured folder and shows it in the listing.
+func (m *model) setSize(path string, size dirSize) {
+	m.sizes[path] = size
+	m.measured++
+	for i := range m.entries {
+		if m.entries[i].path == path && i >= m.pinned {
+			m.entries[i].size = &size
+		}
+	}
+	if m.sort == sortSize {
+		m.reorder()
+		return
+	}
+	// The order stands, so the matches only need the size
+	for i := range m.matched {
+		if m.matched[i].path == path && !m.isPinned(path) {
+			m.matched[i].size = &size
+		}
+	}
+}
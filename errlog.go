@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errorLogLimit caps how many errors are kept; older ones are dropped.
+const errorLogLimit = 100
+
+// logEntry is one non-fatal error, such as an unreadable folder.
+type logEntry struct {
+	at   time.Time
+	path string
+	err  error
+}
+
+// errorLog collects errors that pf works around rather than reports, so
+// they can be checked in the F2 panel or printed with --debug. Background
+// walks add to it too, hence the lock. A nil log discards everything.
+type errorLog struct {
+	mu      sync.Mutex
+	entries []logEntry
+	dropped int // entries discarded to stay within errorLogLimit
+}
+
+func (l *errorLog) add(path string, err error) {
+	if l == nil || err == nil {
+		return
+	}
+	logf("error", "path=%q err=%q", path, err)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{at: time.Now(), path: path, err: err})
+	if len(l.entries) > errorLogLimit {
+		l.dropped += len(l.entries) - errorLogLimit
+		l.entries = l.entries[len(l.entries)-errorLogLimit:]
+	}
+}
+
+// list returns the logged errors, oldest first, and how many were dropped.
+func (l *errorLog) list() ([]logEntry, int) {
+	if l == nil {
+		return nil, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]logEntry(nil), l.entries...), l.dropped
+}
+
+// write prints the log for --debug.
+func (l *errorLog) write(w io.Writer) {
+	entries, dropped := l.list()
+	if dropped > 0 {
+		fmt.Fprintf(w, "pf: %d earlier errors dropped\n", dropped)
+	}
+	for _, e := range entries {
+		fmt.Fprintf(w, "pf: %s %s: %v\n", e.at.Format("15:04:05"), e.path, e.err)
+	}
+}
+
+func (m model) errorsView() string {
+	entries, dropped := m.errLog.list()
+	var lines []string
+	lines = append(lines, "")
+	lines = append(lines, "  \033[1;34mRecent errors\033[0m")
+	lines = append(lines, "")
+	if len(entries) == 0 {
+		lines = append(lines, "  \033[90mNo errors so far\033[0m")
+	}
+	// Newest first, as many as fit on screen at two lines each
+	room := len(entries)
+	if m.height > 0 {
+		room = max(1, (m.height-8)/2)
+	}
+	for i := len(entries) - 1; i >= 0 && len(entries)-i <= room; i-- {
+		e := entries[i]
+		lines = append(lines, "  \033[90m"+e.at.Format("15:04:05")+"\033[0m "+abbreviateHome(e.path))
+		lines = append(lines, "           \033[31m"+e.err.Error()+"\033[0m")
+	}
+	if hidden := max(0, len(entries)-room) + dropped; hidden > 0 {
+		lines = append(lines, fmt.Sprintf("  \033[90m…and %d older\033[0m", hidden))
+	}
+	lines = append(lines, "")
+	lines = append(lines, "  \033[90mPress Esc or "+m.keys.label(actErrors)+" to close\033[0m")
+	lines = append(lines, "")
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,66 @@
+package main
+
+import "unicode/utf8"
+
+// On wide terminals, lists that would need scrolling and have short names
+// are drawn in columns, flowing top to bottom and then left to right.
+const (
+	gridMinWidth = 80 // narrower terminals always use one column
+	gridMaxName  = 30 // a longer name switches back to one column
+	gridMaxCols  = 4
+	gridGap      = 2 // spaces between columns
+)
+
+// columns returns how many columns the list is drawn in.
+func (m model) columns() int {
+	filtered := m.filtered()
+	// Annotations need the full width, so annotated lists stay in one column
+	if m.width < gridMinWidth || m.annotated() || len(filtered) <= m.height-4 {
+		return 1
+	}
+	width := cellWidth(filtered)
+	if width-2-gridGap > gridMaxName {
+		return 1
+	}
+	return max(1, min(gridMaxCols, m.width/width))
+}
+
+// cellWidth returns the width of one grid column: the "> " prefix, the
+// longest name and the gap.
+func cellWidth(items []item) int {
+	longest := 0
+	for _, it := range items {
+		longest = max(longest, utf8.RuneCountInString(it.name))
+	}
+	return 2 + longest + gridGap
+}
+
+// gridRows returns how many rows the list takes up.
+func (m model) gridRows() int {
+	cols := m.columns()
+	return (len(m.filtered()) + cols - 1) / cols
+}
+
+// cursorRow returns the row the cursor is on.
+func (m model) cursorRow() int {
+	rows := m.gridRows()
+	if rows == 0 {
+		return 0
+	}
+	return m.cursor % rows
+}
+
+// moveColumn moves the cursor one column left (-1) or right (+1), staying
+// put when there is no item there. It reports whether the cursor moved.
+func (m *model) moveColumn(dir int) bool {
+	if m.columns() == 1 {
+		return false
+	}
+	target := m.cursor + dir*m.gridRows()
+	if target < 0 || target >= len(m.filtered()) {
+		return false
+	}
+	m.cursor = target
+	m.fixScroll()
+	return true
+}
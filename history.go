@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historyLimit caps how many filters are remembered.
+const historyLimit = 100
+
+// filterHistory remembers the filters used to open or select a folder, so
+// they can be recalled later in the session (or in later sessions with
+// --save-history).
+type filterHistory struct {
+	entries []string // oldest first
+	pos     int      // entry being shown while browsing, len(entries) when not
+	draft   string   // filter typed before browsing started
+}
+
+// historyPath returns the file --save-history keeps filters in, or "" when
+// there is no state directory.
+func historyPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "filter-history")
+}
+
+// loadHistory reads saved filters from path. A missing or unreadable file
+// just means an empty history.
+func loadHistory(path string) filterHistory {
+	var h filterHistory
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line != "" {
+					h.entries = append(h.entries, line)
+				}
+			}
+		}
+	}
+	if len(h.entries) > historyLimit {
+		h.entries = h.entries[len(h.entries)-historyLimit:]
+	}
+	h.pos = len(h.entries)
+	return h
+}
+
+// save writes the history to path, one filter per line.
+func (h filterHistory) save(path string) error {
+	if path == "" {
+		return errNoDataDir
+	}
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600)
+}
+
+// add records filter, skipping blanks and repeats of the latest entry, and
+// stops any browsing.
+func (h *filterHistory) add(filter string) {
+	if strings.TrimSpace(filter) != "" && (len(h.entries) == 0 || h.entries[len(h.entries)-1] != filter) {
+		h.entries = append(h.entries, filter)
+		if len(h.entries) > historyLimit {
+			h.entries = h.entries[len(h.entries)-historyLimit:]
+		}
+	}
+	h.reset()
+}
+
+// reset stops browsing, so the next recall starts from the newest entry.
+func (h *filterHistory) reset() {
+	h.pos = len(h.entries)
+	h.draft = ""
+}
+
+// prev returns the filter before the one shown, remembering current as the
+// draft when browsing starts. ok is false at the oldest entry.
+func (h *filterHistory) prev(current string) (string, bool) {
+	if h.pos == 0 {
+		return "", false
+	}
+	if h.pos == len(h.entries) {
+		h.draft = current
+	}
+	h.pos--
+	return h.entries[h.pos], true
+}
+
+// next returns the filter after the one shown, and the draft after the
+// newest entry. ok is false when not browsing.
+func (h *filterHistory) next() (string, bool) {
+	if h.pos >= len(h.entries) {
+		return "", false
+	}
+	h.pos++
+	if h.pos == len(h.entries) {
+		return h.draft, true
+	}
+	return h.entries[h.pos], true
+}
+
+// lastQueryPath returns the file --save-query keeps the last filter in,
+// or "" when there is no state directory.
+func lastQueryPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "last-query")
+}
+
+// saveLastQuery records the filter a folder was selected with, for
+// --last-query. An empty filter is saved too, so a stale one isn't reused.
+func saveLastQuery(filter string) error {
+	path := lastQueryPath()
+	if path == "" {
+		return errNoDataDir
+	}
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(filter+"\n"), 0600)
+}
+
+// loadLastQuery returns the filter saved by --save-query. ok is false when
+// nothing was saved.
+func loadLastQuery() (string, bool) {
+	data, err := os.ReadFile(lastQueryPath())
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(string(data), "\n"), true
+}
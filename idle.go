@@ -0,0 +1,26 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// idleMsg checks whether --timeout has passed without a key press.
+type idleMsg struct{}
+
+func idleTick(d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(time.Time) tea.Msg { return idleMsg{} })
+}
+
+// checkIdle quits without selecting once --timeout seconds have passed
+// since the last key press, and otherwise waits out the rest. Only one
+// tick is pending at a time; key presses just move lastKey.
+func (m model) checkIdle() (tea.Model, tea.Cmd) {
+	left := seconds(m.opts.timeout) - time.Since(m.lastKey)
+	if left > 0 {
+		return m, idleTick(left)
+	}
+	logf("timeout", "idle=%s", seconds(m.opts.timeout))
+	return m, tea.Quit
+}
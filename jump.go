@@ -0,0 +1,97 @@
+package main
+
+import "strings"
+
+// jumpAlphabet supplies the quick-jump labels, in the order they're given
+// out. When there are more rows on screen than characters, every label
+// takes two, so none is the start of another.
+const jumpAlphabet = "123456789abcdefghijklmnopqrstuvwxyz"
+
+// jumpLabels returns n labels, one character long when they fit, and at
+// most as many as two characters allow.
+func jumpLabels(n int) []string {
+	labels := make([]string, 0, n)
+	if n <= len(jumpAlphabet) {
+		for i := range n {
+			labels = append(labels, jumpAlphabet[i:i+1])
+		}
+		return labels
+	}
+	for _, a := range jumpAlphabet {
+		for _, b := range jumpAlphabet {
+			if len(labels) == n {
+				return labels
+			}
+			labels = append(labels, string(a)+string(b))
+		}
+	}
+	return labels
+}
+
+// shownItems returns the positions in the filtered list of the entries on
+// screen, in list order.
+func (m model) shownItems() []int {
+	n := len(m.filtered())
+	rows := m.gridRows()
+	start, end := m.offset, min(m.offset+m.visibleLines(), rows)
+	var shown []int
+	for c := range m.columns() {
+		for r := start; r < end; r++ {
+			if i := c*rows + r; i < n {
+				shown = append(shown, i)
+			}
+		}
+	}
+	return shown
+}
+
+// jumpTargets returns the label for each entry on screen while choosing
+// where to jump, by its position in the filtered list, or nil otherwise.
+func (m model) jumpTargets() map[int]string {
+	if !m.jumping {
+		return nil
+	}
+	shown := m.shownItems()
+	targets := make(map[int]string, len(shown))
+	for j, label := range jumpLabels(len(shown)) {
+		targets[shown[j]] = label
+	}
+	return targets
+}
+
+// updateJump handles a key typed while the labels are shown: the cursor
+// moves to the entry whose label it completes, and a key that can't be
+// part of a label, such as Esc, puts the labels away.
+func (m model) updateJump(k string) model {
+	typed := m.jumpTyped + k
+	for i, label := range m.jumpTargets() {
+		switch {
+		case label == typed:
+			m.jumping, m.jumpTyped = false, ""
+			m.cursor = i
+			m.fixScroll()
+			return m
+		case strings.HasPrefix(label, typed):
+			m.jumpTyped = typed
+			m.notice = "\033[90mjump: " + typed + "…\033[0m"
+			return m
+		}
+	}
+	m.jumping, m.jumpTyped = false, ""
+	return m
+}
+
+// jumpLine renders an entry with its quick-jump label in the cursor column.
+func (m model) jumpLine(it item, label string, selected bool) string {
+	name := it.name
+	if selected {
+		name = "\033[1;34m" + name + "\033[0m"
+	}
+	line := "\033[1;30;43m" + label + "\033[0m" + strings.Repeat(" ", 2-len(label)) + name
+	link, plain := linkSuffix(it)
+	line += link
+	if note := m.annotation(it); note != "" {
+		line += m.rightAlign("  "+it.name+plain, note)
+	}
+	return line
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Actions that can be bound to keys in keys.toml.
+const (
+	actUp         = "up"
+	actDown       = "down"
+	actOpen       = "open"
+	actSelect     = "select"
+	actSelectHere = "select-current"
+	actParent     = "parent"
+	actBreadcrumb = "breadcrumb"
+	actFullPath   = "full-path"
+	actRefresh    = "refresh"
+	actSort       = "sort"
+	actReverse    = "reverse"
+	actPrevFilter = "prev-filter"
+	actNextFilter = "next-filter"
+	actCreate     = "create"
+	actRename     = "rename"
+	actArchive    = "archive"
+	actReveal     = "reveal"
+	actCopyCd     = "copy-cd"
+	actInvert     = "invert"
+	actJump       = "jump"
+	actSaveSlot   = "save-slot"
+	actSlots      = "slots"
+	actDelete     = "delete"
+	actQuit       = "quit"
+	actHelp       = "help"
+	actErrors     = "errors"
+	actMark       = "mark"
+	actMarks      = "marks"
+	actPrevSib    = "prev-sibling"
+	actNextSib    = "next-sibling"
+)
+
+// defaultBindings are the keys used when keys.toml doesn't override them.
+var defaultBindings = map[string][]string{
+	actUp:         {"up"},
+	actDown:       {"down"},
+	actOpen:       {"enter"},
+	actSelect:     {"tab"},
+	actSelectHere: {"ctrl+@"},
+	actParent:     {"esc"},
+	actBreadcrumb: {"ctrl+b"},
+	actFullPath:   {"ctrl+l"},
+	actRefresh:    {"f5"},
+	actSort:       {"ctrl+s"},
+	actReverse:    {"ctrl+r"},
+	actPrevFilter: {"ctrl+p", "alt+up"},
+	actNextFilter: {"alt+down"},
+	actCreate:     {"ctrl+n"},
+	actRename:     {"ctrl+e"},
+	actArchive:    {"ctrl+a"},
+	actReveal:     {"ctrl+o"},
+	actCopyCd:     {"ctrl+y"},
+	actInvert:     {"alt+i"},
+	actJump:       {"ctrl+g"},
+	actSaveSlot:   {"alt+s"},
+	actSlots:      {"f4"},
+	actDelete:     {"alt+backspace", "ctrl+backspace"},
+	actQuit:       {"ctrl+c"},
+	actHelp:       {"f1"},
+	actErrors:     {"f2"},
+	actMark:       {"ctrl+x"},
+	actMarks:      {"f3"},
+	actPrevSib:    {"alt+left"},
+	actNextSib:    {"alt+right"},
+}
+
+// helpEntry describes one line of the help screen. The same entries drive
+// the footer hints, so remapped keys show up everywhere.
+type helpEntry struct {
+	actions []string // actions sharing the line, e.g. up and down
+	fixed   string   // label for keys that aren't remappable
+	desc    string   // help screen description
+	hint    string   // short footer hint, empty to leave out of the footer
+}
+
+// columnKeys move across columns. They can't be bound, except that
+// --enter-selects has → open folders too.
+const columnKeys = "← / →"
+
+var helpEntries = []helpEntry{
+	{actions: []string{actUp, actDown}, desc: "Navigate list", hint: "nav"},
+	{fixed: columnKeys, desc: "Move across columns (wide terminals)"},
+	{actions: []string{actOpen}, desc: "Open folder", hint: "open"},
+	{actions: []string{actSelect}, desc: "Select & cd to folder", hint: "select"},
+	{actions: []string{actMark}, desc: "Mark folder; Tab then selects all marked (--multi)"},
+	{actions: []string{actMarks}, desc: "Review and unmark marked folders"},
+	{actions: []string{actSelectHere}, desc: "Select & cd to the folder you're in (. with no filter)", hint: "here"},
+	{actions: []string{actJump}, desc: "Label the folders shown; type a label to move there"},
+	{actions: []string{actParent}, desc: "Go to parent folder"},
+	{actions: []string{actPrevSib, actNextSib}, desc: "Go to the previous / next sibling folder"},
+	{actions: []string{actBreadcrumb}, desc: "Jump to a folder in the path (←/→, Enter)"},
+	{fixed: "Backspace", desc: "Clear filter character"},
+	{fixed: "Ctrl+U", desc: "Clear the whole filter"},
+	{actions: []string{actPrevFilter, actNextFilter}, desc: "Recall earlier / later filters"},
+	{actions: []string{actSaveSlot}, desc: "Save the filter to a slot (then 1-9)"},
+	{actions: []string{actSlots}, desc: "Show saved filters; 1-9 recalls one"},
+	{actions: []string{actInvert}, desc: "Show the folders the filter doesn't match"},
+	{actions: []string{actFullPath}, desc: "Toggle ~ / full path in header"},
+	{actions: []string{actRefresh}, desc: "Reload the folder from disk"},
+	{actions: []string{actSort}, desc: "Cycle sort mode (name, natural, modified, none)"},
+	{actions: []string{actReverse}, desc: "Reverse sort order"},
+	{actions: []string{actCreate}, desc: "Create new folder", hint: "new"},
+	{actions: []string{actRename}, desc: "Rename folder"},
+	{actions: []string{actArchive}, desc: "Archive folder (~/Dev-Archive)"},
+	{actions: []string{actReveal}, desc: "Open folder in the file manager"},
+	{actions: []string{actCopyCd}, desc: "Copy a cd command for the folder"},
+	{actions: []string{actDelete}, desc: "Delete selected folder"},
+	{actions: []string{actQuit}, desc: "Quit without select"},
+	{actions: []string{actHelp}, desc: "Toggle this help", hint: "help"},
+	{actions: []string{actErrors}, desc: "Show recent errors (unreadable folders etc.)"},
+}
+
+// keymap maps Bubble Tea key strings to actions and back.
+type keymap struct {
+	keys    map[string][]string // action -> bound keys
+	actions map[string]string   // key -> action
+}
+
+func defaultKeymap() keymap {
+	km, _ := newKeymap(defaultBindings)
+	return km
+}
+
+// reservedKeys edit the filter or move around the list when no action
+// claims them, so binding one would take that away.
+var reservedKeys = map[string]string{
+	"backspace": "deletes a filter character",
+	"ctrl+u":    "clears the filter",
+	"left":      "moves across columns",
+	"right":     "moves across columns",
+	".":         "selects the folder you're in",
+}
+
+// closeKeys close overlays such as the marks list. Overlays let the quit
+// keys through, so these can't quit.
+var closeKeys = map[string]string{
+	"esc":    "closes overlays and dialogs",
+	"delete": "unmarks folders in the marks list",
+}
+
+// newKeymap builds a keymap, failing when a key is bound to two actions
+// or takes one that pf handles itself.
+func newKeymap(bindings map[string][]string) (keymap, error) {
+	km := keymap{keys: bindings, actions: make(map[string]string)}
+	for action, keys := range bindings {
+		for _, k := range keys {
+			if use, ok := reservedKeys[k]; ok {
+				return km, fmt.Errorf("key %q can't be bound to %q: it %s", k, action, use)
+			}
+			if use, ok := closeKeys[k]; ok && action == actQuit {
+				return km, fmt.Errorf("key %q can't be bound to %q: it %s", k, action, use)
+			}
+			if other, ok := km.actions[k]; ok && other != action {
+				return km, fmt.Errorf("key %q is bound to both %q and %q", k, other, action)
+			}
+			km.actions[k] = action
+		}
+	}
+	return km, nil
+}
+
+// enterSelects rebinds the keys for --enter-selects: Enter selects, as
+// Tab does, and → opens folders in its place. Nothing changes when Enter
+// isn't bound to open, since keys.toml has already moved it.
+func (km keymap) enterSelects() (keymap, error) {
+	if !slices.Contains(km.keys[actOpen], "enter") {
+		return km, nil
+	}
+	bindings := maps.Clone(km.keys)
+	bindings[actOpen] = slices.DeleteFunc(slices.Clone(bindings[actOpen]), func(k string) bool { return k == "enter" })
+	bindings[actSelect] = append([]string{"enter"}, bindings[actSelect]...)
+	km, err := newKeymap(bindings)
+	if err != nil {
+		return km, err
+	}
+	// → is reserved for moving across columns, which keys.toml can't take
+	// away, but this mode opens folders with it once there are no more
+	// columns to move to
+	km.keys[actOpen] = append(km.keys[actOpen], "right")
+	km.actions["right"] = actOpen
+	return km, nil
+}
+
+// action returns the action bound to key, or "" if none.
+func (km keymap) action(key string) string {
+	return km.actions[key]
+}
+
+// label returns the keys bound to action formatted for display.
+func (km keymap) label(action string) string {
+	var labels []string
+	for _, k := range km.keys[action] {
+		labels = append(labels, keyLabel(k))
+	}
+	return strings.Join(labels, " / ")
+}
+
+// hintLabel returns the first key bound to action in compact footer form.
+func (km keymap) hintLabel(action string) string {
+	keys := km.keys[action]
+	if len(keys) == 0 {
+		return ""
+	}
+	if keys[0] == "ctrl+@" {
+		return "^Space"
+	}
+	if rest, ok := strings.CutPrefix(keys[0], "ctrl+"); ok && len(rest) == 1 {
+		return "^" + strings.ToUpper(rest)
+	}
+	return keyLabel(keys[0])
+}
+
+// helpLines renders helpEntries with the keys currently bound.
+func (km keymap) helpLines() []string {
+	var lines []string
+	for _, e := range helpEntries {
+		label := e.fixed
+		if label == "" {
+			var labels []string
+			for _, a := range e.actions {
+				labels = append(labels, km.label(a))
+			}
+			label = strings.Join(labels, " / ")
+		}
+		desc := e.desc
+		if e.fixed == columnKeys && km.action("right") == actOpen {
+			desc = "Move across columns; → opens folders from the last one"
+		}
+		pad := max(1, 12-utf8.RuneCountInString(label))
+		lines = append(lines, "  \033[1m"+label+"\033[0m"+strings.Repeat(" ", pad)+desc)
+	}
+	return lines
+}
+
+// footerHints renders the short key hints for the footer bar.
+func (km keymap) footerHints() string {
+	var hints []string
+	for _, e := range helpEntries {
+		if e.hint == "" {
+			continue
+		}
+		var label string
+		for _, a := range e.actions {
+			label += km.hintLabel(a)
+		}
+		hints = append(hints, label+" "+e.hint)
+	}
+	return strings.Join(hints, " • ")
+}
+
+// keyLabel turns a Bubble Tea key string like "ctrl+n" into "Ctrl+N".
+func keyLabel(k string) string {
+	switch k {
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case " ":
+		return "Space"
+	case "ctrl+@":
+		// What terminals send for Ctrl+Space
+		return "Ctrl+Space"
+	}
+	parts := strings.Split(k, "+")
+	for i, p := range parts {
+		switch {
+		case p == "backspace" && i > 0:
+			parts[i] = "⌫"
+		case len(p) == 1:
+			parts[i] = strings.ToUpper(p)
+		default:
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "+")
+}
+
+// keymapPath returns the location of the key bindings file, or "" when
+// there is no config directory.
+func keymapPath() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "keys.toml")
+}
+
+// loadKeymap reads key bindings from path on top of the defaults. A missing
+// file is not an error. The file uses a small TOML subset:
+//
+//	up = "ctrl+p"
+//	down = ["ctrl+n", "down"]
+func loadKeymap(path string) (keymap, error) {
+	bindings := make(map[string][]string, len(defaultBindings))
+	for action, keys := range defaultBindings {
+		bindings[action] = keys
+	}
+
+	if path == "" {
+		return newKeymap(bindings)
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return newKeymap(bindings)
+	}
+	if err != nil {
+		return keymap{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		action, value, ok := strings.Cut(line, "=")
+		action = strings.TrimSpace(action)
+		if !ok {
+			return keymap{}, fmt.Errorf("%s:%d: expected action = \"key\"", path, lineNo)
+		}
+		if _, known := defaultBindings[action]; !known {
+			return keymap{}, fmt.Errorf("%s:%d: unknown action %q", path, lineNo, action)
+		}
+		keys, err := parseTOMLStrings(value)
+		if err != nil {
+			return keymap{}, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		bindings[action] = keys
+	}
+	if err := scanner.Err(); err != nil {
+		return keymap{}, err
+	}
+
+	km, err := newKeymap(bindings)
+	if err != nil {
+		return km, fmt.Errorf("%s: %v", path, err)
+	}
+	return km, nil
+}
+
+// tomlString matches a double-quoted TOML string.
+var tomlString = regexp.MustCompile(`"([^"]*)"`)
+
+// parseTOMLStrings parses a quoted string or an array of quoted strings.
+func parseTOMLStrings(value string) ([]string, error) {
+	var result []string
+	for _, match := range tomlString.FindAllStringSubmatch(value, -1) {
+		result = append(result, match[1])
+	}
+	rest := tomlString.ReplaceAllString(value, "")
+	if i := strings.Index(rest, "#"); i >= 0 {
+		rest = rest[:i]
+	}
+	if strings.Trim(rest, "[], \t") != "" || len(result) == 0 {
+		return nil, fmt.Errorf("expected a quoted string or array of strings, got %s", strings.TrimSpace(value))
+	}
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeKeys(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeymap(t *testing.T) {
+	km, err := loadKeymap(writeKeys(t, `
+# vim-ish
+up = "ctrl+k"
+down = ["ctrl+j", "down"]  # keep the arrow
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{
+		"ctrl+k": actUp,
+		"ctrl+j": actDown,
+		"down":   actDown,
+		"up":     "",
+		"enter":  actOpen,
+	} {
+		if got := km.action(k); got != want {
+			t.Errorf("action(%q) = %q, want %q", k, got, want)
+		}
+	}
+	if !strings.Contains(strings.Join(km.helpLines(), "\n"), "Ctrl+K") {
+		t.Error("help doesn't show the remapped up key")
+	}
+}
+
+func TestLoadKeymapMissingFile(t *testing.T) {
+	km, err := loadKeymap(filepath.Join(t.TempDir(), "keys.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km.action("enter") != actOpen {
+		t.Error("defaults not used without a keys.toml")
+	}
+}
+
+func TestLoadKeymapErrors(t *testing.T) {
+	for _, tt := range []struct{ file, want string }{
+		{`teleport = "ctrl+t"`, `unknown action "teleport"`},
+		{`up "ctrl+k"`, `expected action = "key"`},
+		{`up = ctrl+k`, "expected a quoted string"},
+		{`help = "ctrl+c"`, `key "ctrl+c" is bound to both`},
+		{`quit = "backspace"`, `key "backspace" can't be bound to "quit": it deletes a filter character`},
+		{`open = "right"`, `key "right" can't be bound to "open": it moves across columns`},
+		{`select-current = "."`, `key "." can't be bound`},
+		{`help = "ctrl+u"`, `key "ctrl+u" can't be bound`},
+		{`quit = "esc"`, `key "esc" can't be bound to "quit": it closes overlays and dialogs`},
+		{`quit = "delete"`, `key "delete" can't be bound to "quit"`},
+	} {
+		_, err := loadKeymap(writeKeys(t, tt.file))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.file, err, tt.want)
+		}
+	}
+}
+
+func TestCloseKeysBindOtherActions(t *testing.T) {
+	km, err := loadKeymap(writeKeys(t, `delete = "delete"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km.action("delete") != actDelete {
+		t.Error("delete key not bound to delete")
+	}
+}
+
+func TestEnterSelects(t *testing.T) {
+	km, err := defaultKeymap().enterSelects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km.action("enter") != actSelect || km.action("right") != actOpen || km.action("tab") != actSelect {
+		t.Errorf("enter %q, right %q, tab %q", km.action("enter"), km.action("right"), km.action("tab"))
+	}
+	if defaultKeymap().action("right") != "" {
+		t.Error("enterSelects changed the default keymap")
+	}
+}
+
+func TestReadmeKeymapExample(t *testing.T) {
+	readme, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, example, _ := strings.Cut(string(readme), "## Custom key bindings")
+	_, example, _ = strings.Cut(example, "```toml\n")
+	example, _, _ = strings.Cut(example, "```")
+	if _, err := loadKeymap(writeKeys(t, example)); err != nil {
+		t.Errorf("README example: %v", err)
+	}
+}
+
+func TestEnterSelectsRightInColumns(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 40 {
+		mkdirs(t, dir, fmt.Sprintf("dir%02d", i))
+	}
+	m := resize(testModel(t, dir, "--enter-selects"), 120, 12)
+	if m.columns() < 2 {
+		t.Fatalf("%d columns on a wide terminal", m.columns())
+	}
+	m = press(m, "down")
+	start := cursorName(m)
+	// → goes across the columns, then opens from the last one
+	for c := 1; c < m.columns(); c++ {
+		m = press(m, "right")
+		if m.root != dir || cursorName(m) == start {
+			t.Fatalf("→ in column %d: in %s on %s", c, m.root, cursorName(m))
+		}
+	}
+	name := cursorName(m)
+	m = press(m, "right")
+	if m.root != filepath.Join(dir, name) {
+		t.Errorf("→ in the last column went to %s, want %s", m.root, name)
+	}
+	// A single column opens at once
+	m = resize(testModel(t, dir, "--enter-selects"), 40, 12)
+	m = press(m, "down", "right")
+	if m.root != filepath.Join(dir, "dir00") {
+		t.Errorf("→ in one column went to %s, want dir00", m.root)
+	}
+	help := strings.Join(m.keys.helpLines(), "\n")
+	if !strings.Contains(help, "→ opens folders from the last one") {
+		t.Errorf("help doesn't explain →:\n%s", help)
+	}
+}
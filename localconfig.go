@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localConfigName is the per-directory settings file read with
+// --allow-local-config.
+const localConfigName = ".pf"
+
+// localConfig holds the settings from a .pf file. They apply while
+// navigating anywhere below the folder that contains it.
+type localConfig struct {
+	path    string   // the .pf file, "" when none applies
+	ignore  []string // folder name patterns to leave out, as for filepath.Match
+	show    []string // dot-folder name patterns to list all the same
+	root    string   // absolute folder pf may not navigate above, "" for none
+	sort    sortMode // initial sort mode when hasSort is set
+	hasSort bool
+	cursor  string // where fresh listings put the cursor, "" for --cursor-start
+	match   string // matching algorithm, "" for --match
+	err     error  // problem reading the file, shown in the status line
+}
+
+// findLocalConfig returns the nearest .pf file at or above dir, stopping at
+// the repository root (a folder containing .git) or the filesystem root.
+func findLocalConfig(dir string) string {
+	for {
+		path := filepath.Join(dir, localConfigName)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadLocalConfig reads a .pf file. It uses the same TOML subset as
+// keys.toml, and relative paths are resolved against the file's folder:
+//
+//	ignore = ["build", "dist*"]
+//	show = [".github"]
+//	root = "."
+//	sort = "natural"
+//	cursor = "first"
+//	match = "substring"
+func loadLocalConfig(path string) localConfig {
+	cfg := localConfig{path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		cfg.err = err
+		return cfg
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok {
+			cfg.err = fmt.Errorf("%s:%d: expected setting = \"value\"", path, lineNo)
+			return cfg
+		}
+		values, err := parseTOMLStrings(value)
+		if err != nil {
+			cfg.err = fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			return cfg
+		}
+		switch key {
+		case "ignore":
+			for _, pattern := range values {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					cfg.err = fmt.Errorf("%s:%d: bad pattern %q", path, lineNo, pattern)
+					return cfg
+				}
+			}
+			cfg.ignore = append(cfg.ignore, values...)
+		case "show":
+			for _, pattern := range values {
+				if _, err := filepath.Match(pattern, ""); err != nil {
+					cfg.err = fmt.Errorf("%s:%d: bad pattern %q", path, lineNo, pattern)
+					return cfg
+				}
+			}
+			cfg.show = append(cfg.show, values...)
+		case "root":
+			root := values[0]
+			if !filepath.IsAbs(root) {
+				root = filepath.Join(dir, root)
+			}
+			cfg.root = filepath.Clean(root)
+		case "sort":
+			mode, err := parseSortMode(values[0])
+			if err != nil {
+				cfg.err = fmt.Errorf("%s:%d: %v", path, lineNo, err)
+				return cfg
+			}
+			cfg.sort, cfg.hasSort = mode, true
+		case "cursor":
+			if err := checkCursorStart(values[0]); err != nil {
+				cfg.err = fmt.Errorf("%s:%d: %v", path, lineNo, err)
+				return cfg
+			}
+			cfg.cursor = values[0]
+		case "match":
+			if err := checkMatcher(values[0]); err != nil {
+				cfg.err = fmt.Errorf("%s:%d: %v", path, lineNo, err)
+				return cfg
+			}
+			cfg.match = values[0]
+		default:
+			cfg.err = fmt.Errorf("%s:%d: unknown setting %q", path, lineNo, key)
+			return cfg
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		cfg.err = err
+	}
+	return cfg
+}
+
+// applyLocalConfig picks up the .pf file covering the current folder when
+// --allow-local-config is set. A file's sort mode takes effect on entering
+// its tree, and the --sort mode comes back on leaving it.
+func (m *model) applyLocalConfig() {
+	if !m.opts.localConfig {
+		return
+	}
+	path := findLocalConfig(m.root)
+	if path == m.local.path {
+		return
+	}
+	m.local = localConfig{}
+	if path != "" {
+		m.local = loadLocalConfig(path)
+		m.errLog.add(path, m.local.err)
+	}
+	m.sort = m.opts.sort
+	if m.local.hasSort {
+		m.sort = m.local.sort
+	}
+}
+
+// boundary returns the folder pf may not navigate above: the --root
+// folder, narrowed by a .pf file's root setting.
+func (m model) boundary() string {
+	r := m.local.root
+	if r == "" || !isWithin(m.root, r) {
+		return m.opts.boundary
+	}
+	if m.opts.boundary != "" && !isWithin(r, m.opts.boundary) {
+		return m.opts.boundary
+	}
+	return r
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// lockFile is unavailable here, so --log-jumps and --append-to rely on
+// O_APPEND alone.
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// emitter prints the paths selected with --loop while the picker runs.
+type emitter func(paths []string) error
+
+// keepPicking hands a selection made with --loop to m.emit and resets the
+// picker for the next one, in place of quitting. It returns the command
+// to run instead of cmd, which is the tea.Quit of the selection.
+func (m *model) keepPicking(cmd tea.Cmd) tea.Cmd {
+	paths := m.chosen()
+	if len(paths) == 0 || m.emit == nil {
+		return cmd
+	}
+	err := m.emit(paths)
+	m.selected, m.picked = "", nil
+	m.marks.clear()
+	if !m.opts.stickyFilter {
+		m.setFilter("")
+		m.history.reset()
+	}
+	m.cursor = m.bestMatch()
+	m.offset = 0
+	m.fixScroll()
+	m.notice = "\033[90mprinted " + abbreviateHome(paths[0]) + "\033[0m"
+	if len(paths) > 1 {
+		m.notice = fmt.Sprintf("\033[90mprinted %d folders\033[0m", len(paths))
+	}
+	if err != nil {
+		// Stderr belongs to the picker, so the error waits in the log
+		m.errLog.add("output", err)
+		first, _, _ := strings.Cut(err.Error(), "\n")
+		m.notice = "\033[31m" + first + "\033[0m"
+	}
+	return nil
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"sort"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -13,115 +19,994 @@ import (
 const version = "1.1.3"
 
 type model struct {
-	items         []string
-	paths         []string
-	cursor        int
-	filter        string
-	selected      string
-	root          string
-	height        int
-	offset        int    // scroll offset
-	showHelp      bool   // show help screen
-	confirmDelete bool   // show delete confirmation
-	deleteTarget  string // path to delete
-	deleteError   string // error message after delete attempt
-	createMode    bool   // show create folder input
-	newFolderName string // name for new folder
-	createError   string // error message after create attempt
+	entries        []item
+	matched        []item // entries matching the filter, kept by rematch
+	cursor         int
+	filter         string
+	selected       string
+	root           string
+	height         int
+	width          int
+	offset         int    // scroll offset
+	showHelp       bool   // show help screen
+	confirmDelete  bool   // show delete confirmation
+	deleteTarget   string // path to delete
+	deleteError    string // error message after delete attempt
+	createMode     bool   // show create folder input
+	newFolderName  string // name for new folder
+	createError    string // error message after create attempt
 	confirmArchive bool   // show archive confirmation
 	archiveTarget  string // path to archive
 	archiveError   string // error message after archive attempt
+	renameMode     bool   // show rename folder input
+	renameTarget   string // path being renamed
+	renameName     string // new name for the folder
+	renameError    string // error message shown inside the rename input
+	crumbMode      bool   // navigating the path header segments
+	crumbCursor    int    // selected segment in breadcrumb mode
+	sort           sortMode
+	reverse        bool // reverse the sort order
+	keys           keymap
+	fullPath       bool // show the absolute path in the header instead of ~
+	pinned         int  // leading entries ([current], ..) that never move
+	walker         walker
+	walking        bool         // a background walk is still sending results
+	walkProgress   walkProgress // walk totals as last shown in the status line
+	spinning       bool         // the spinner tick loop is running
+	spinFrame      int
+	denied         bool        // the current folder couldn't be read for lack of permission
+	local          localConfig // settings from a .pf file, with --allow-local-config
+	skipped        skipCounts  // folders the listing left out, for --count-skipped
+	notice         string      // one-off message for the status line, cleared on the next key
+	history        filterHistory
+	errLog         *errorLog // non-fatal errors, shown with F2
+	showErrors     bool      // show the recent errors panel
+	marks          markSet   // folders marked with --multi
+	showMarks      bool      // show the marked folders overlay
+	marksCursor    int       // highlighted row in the marked folders overlay
+	picked         []string  // every marked folder, when Tab selected them with --multi
+	manifest       string    // manifest file the listing came from, with --manifest
+	reading        dirRead   // latest folder read, with --read-timeout
+	readPending    bool      // reading hasn't finished yet
+	readTimedOut   bool      // reading was abandoned after --read-timeout
+	sizer          sizer     // measures folder sizes, with --du
+	sizing         bool      // sizer is still measuring
+	measured       int       // folders sizer has measured so far
+	sizes          sizeCache // sizes measured this session
+	shownCount     int       // match count drawn with --animate
+	counting       bool      // shownCount is moving toward the match count
+	lastKey        time.Time // when a key was last pressed, for --timeout
+	inverted       bool      // show the folders the filter doesn't match
+	protected      []string  // folders rename, archive and delete may not touch, from the protected file
+	emit           emitter   // with --loop, prints each selection as it's made
+	slots          searches  // filters saved to numbered slots
+	savingSlot     bool      // the next key picks the slot to save the filter to
+	showSlots      bool      // show the saved filters overlay
+	jumping        bool      // labels are shown on the rows for a quick jump
+	jumpTyped      string    // the start of a two-character label typed so far
+	cursorTarget   string    // folder for the cursor once the pending read finishes
+	opts           options
 }
 
-func newModel(start string) model {
+// expandPath turns a start path as typed by the user into an absolute
+// path, expanding ~ and defaulting to the current directory.
+func expandPath(start string) string {
 	if start == "" {
 		start, _ = os.Getwd()
 	}
 	if start == "~" {
 		start, _ = os.UserHomeDir()
 	}
-	if strings.HasPrefix(start, "~/") {
+	if strings.HasPrefix(start, "~/") || strings.HasPrefix(start, "~"+string(filepath.Separator)) {
 		home, _ := os.UserHomeDir()
 		start = home + start[1:]
 	}
+	if abs, err := filepath.Abs(start); err == nil {
+		start = abs
+	}
+	return start
+}
+
+// resolveStart expands start like expandPath and checks it is a directory.
+func resolveStart(start string) (string, error) {
+	path := expandPath(start)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", path)
+	}
+	return path, nil
+}
+
+func newModel(opts options) model {
+	start := expandPath(opts.start)
+
+	m := model{
+		root:   start,
+		sort:   opts.sort,
+		keys:   defaultKeymap(),
+		opts:   opts,
+		errLog: &errorLog{},
+		filter: opts.query,
+		sizes:  make(sizeCache),
+	}
+	if opts.saveHistory {
+		m.history = loadHistory(historyPath())
+		m.slots = loadSlots(slotsPath())
+	}
+	m.reload()
+	m.cursor = m.bestMatch()
+	m.shownCount = m.matchCount()
+	m.lastKey = time.Now()
+	return m
+}
+
+// currentEntry returns the list entry for root itself, shown first so the
+// folder you're in can be selected.
+func currentEntry(root string) item {
+	return item{name: "[. select this folder]", path: root, stub: true}
+}
+
+// loadDir returns the subfolders of root, unsorted, logging folders whose
+// info can't be read. err reports a problem reading root itself.
+func loadDir(root string, filter dirFilter, log *errorLog) (items []item, skipped skipCounts, err error) {
+	dirs, skipped, err := scanDirs(root, filter)
+	now := time.Now()
+	for i, e := range dirs {
+		it := item{name: e.Name(), path: filepath.Join(root, e.Name()), order: i}
+		if info, err := e.Info(); err == nil {
+			it.modTime = info.ModTime()
+		} else {
+			log.add(it.path, err)
+		}
+		if !filter.age.allows(it.modTime, now) {
+			skipped.add(skipAge)
+			continue
+		}
+		items = append(items, it)
+	}
+	return items, skipped, err
+}
+
+// dirFilter decides which folders appear in listings and walks.
+type dirFilter struct {
+	hiddenOnly bool     // list only dot-folders instead of skipping them
+	noIgnore   bool     // keep node_modules and vendor
+	ignore     []string // extra name patterns to leave out, from a .pf file
+	show       []string // dot-folder name patterns listed all the same
+	only       string   // with --only, a pattern names must match to be listed
+	age        ageLimit // with --modified-within or --older-than, the ages listed
+}
+
+// listFilter returns the folder filter for the current options. With
+// --no-ignore, the .pf ignore patterns are left out along with the
+// built-in ones.
+func (m model) listFilter() dirFilter {
+	f := dirFilter{hiddenOnly: m.opts.hiddenOnly, noIgnore: m.opts.noIgnore, only: m.opts.only, age: m.opts.age}
+	if !m.opts.noIgnore {
+		f.ignore = m.local.ignore
+	}
+	f.show = append(slices.Clone(m.opts.showHidden), m.local.show...)
+	return f
+}
+
+// Reasons a folder is left out of listings and walks.
+type skipReason int
+
+const (
+	skipNone    skipReason = iota
+	skipHidden             // a dot-folder
+	skipVisible            // not a dot-folder, with --hidden-only
+	skipIgnored            // node_modules, vendor or a .pf ignore pattern
+	skipOnly               // not matching the --only pattern
+	skipAge                // modified outside the --modified-within or --older-than window
+)
+
+// skip reports whether a folder is left out of listings and walks.
+func (f dirFilter) skip(name string) bool {
+	return f.reason(name) != skipNone
+}
+
+// reason returns why a folder is left out, or skipNone to list it. The
+// ignore patterns come first, so a dot-folder that is both ignored and in
+// --show-hidden stays out.
+func (f dirFilter) reason(name string) skipReason {
+	for _, pattern := range f.ignore {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return skipIgnored
+		}
+	}
+	hidden := strings.HasPrefix(name, ".")
+	if f.hiddenOnly {
+		if !hidden {
+			return skipVisible
+		}
+		return skipNone
+	}
+	if hidden && !f.shows(name) {
+		return skipHidden
+	}
+	if !f.noIgnore && (name == "node_modules" || name == "vendor") {
+		return skipIgnored
+	}
+	if f.only != "" {
+		if ok, _ := filepath.Match(f.only, name); !ok {
+			return skipOnly
+		}
+	}
+	return skipNone
+}
+
+// shows reports whether name matches a --show-hidden or .pf show pattern.
+func (f dirFilter) shows(name string) bool {
+	for _, pattern := range f.show {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// skipCounts tallies the folders a listing left out, by reason.
+type skipCounts struct {
+	hidden  int
+	visible int
+	ignored int
+	only    int
+	age     int
+}
+
+func (c *skipCounts) add(r skipReason) {
+	switch r {
+	case skipHidden:
+		c.hidden++
+	case skipVisible:
+		c.visible++
+	case skipIgnored:
+		c.ignored++
+	case skipOnly:
+		c.only++
+	case skipAge:
+		c.age++
+	}
+}
 
-	items, paths := loadDir(start)
-	return model{
-		root:  start,
-		items: items,
-		paths: paths,
+// String returns the --count-skipped note, such as "3 hidden, 1 ignored",
+// or "" when nothing was left out.
+func (c skipCounts) String() string {
+	var parts []string
+	if c.hidden > 0 {
+		parts = append(parts, fmt.Sprintf("%d hidden", c.hidden))
+	}
+	if c.visible > 0 {
+		parts = append(parts, fmt.Sprintf("%d not hidden", c.visible))
+	}
+	if c.ignored > 0 {
+		parts = append(parts, fmt.Sprintf("%d ignored", c.ignored))
 	}
+	if c.only > 0 {
+		parts = append(parts, fmt.Sprintf("%d not matching --only", c.only))
+	}
+	if c.age > 0 {
+		parts = append(parts, fmt.Sprintf("%d outside the age window", c.age))
+	}
+	return strings.Join(parts, ", ")
 }
 
-func loadDir(root string) ([]string, []string) {
-	// Show current folder name as first item (to select current dir)
-	currentName := filepath.Base(root)
-	if root == "/" {
-		currentName = "/"
+// readDir returns the entries of dir in the order the filesystem returns
+// them. Errors leave the list empty (or partial) rather than failing, so
+// one unreadable folder can't break a listing or abort a walk; the error
+// is returned for callers to show or log.
+func readDir(dir string) ([]os.DirEntry, error) {
+	// Unlike os.ReadDir, File.ReadDir doesn't sort
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
 	}
-	items := []string{"[" + currentName + "]"}
-	paths := []string{root}
+	defer f.Close()
+	return f.ReadDir(-1)
+}
 
-	entries, _ := os.ReadDir(root)
-	var dirs []string
-	dirMap := make(map[string]string)
+// listDirs returns the visible subfolders of root in the order the
+// filesystem returns them, and any error reading root.
+func listDirs(root string, filter dirFilter) ([]os.DirEntry, error) {
+	dirs, _, err := scanDirs(root, filter)
+	return dirs, err
+}
 
+// scanDirs is listDirs, also counting the subfolders the filter left out.
+func scanDirs(root string, filter dirFilter) (dirs []os.DirEntry, skipped skipCounts, err error) {
+	entries, err := readDir(root)
 	for _, e := range entries {
-		if strings.HasPrefix(e.Name(), ".") {
+		if !isDirEntry(root, e) {
 			continue
 		}
-		if e.Name() == "node_modules" || e.Name() == "vendor" {
+		if r := filter.reason(e.Name()); r != skipNone {
+			skipped.add(r)
 			continue
 		}
-		// Check if it's a directory or a symlink to a directory
-		isDir := e.IsDir()
-		if !isDir && e.Type()&os.ModeSymlink != 0 {
-			// It's a symlink - check if target is a directory
-			targetPath := filepath.Join(root, e.Name())
-			if info, err := os.Stat(targetPath); err == nil && info.IsDir() {
-				isDir = true
+		dirs = append(dirs, e)
+	}
+	return dirs, skipped, err
+}
+
+// isDirEntry reports whether e, in root, is a directory or a symlink to one.
+func isDirEntry(root string, e os.DirEntry) bool {
+	if e.IsDir() {
+		return true
+	}
+	if e.Type()&os.ModeSymlink == 0 {
+		return false
+	}
+	// It's a symlink - check if target is a directory
+	info, err := os.Stat(filepath.Join(root, e.Name()))
+	return err == nil && info.IsDir()
+}
+
+// isRoot reports whether path is a filesystem root, such as / or C:\.
+func isRoot(path string) bool {
+	return filepath.Dir(path) == path
+}
+
+// isWithin reports whether path is dir itself or lies inside it. Unlike a
+// plain prefix check, /home/user2 is not within /home/user.
+func isWithin(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
+
+// abbreviateHome replaces a leading home directory with ~.
+func abbreviateHome(path string) string {
+	return replaceHome(path, "~")
+}
+
+// replaceHome replaces a leading home directory with prefix. Folders that
+// merely start with the same letters, like /home/al for /home/alex, are
+// left alone.
+func replaceHome(path, prefix string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" || isRoot(home) || !isWithin(path, home) {
+		return path
+	}
+	return prefix + path[len(home):]
+}
+
+func (m model) Init() tea.Cmd {
+	// Pick up background work newModel's reload started
+	var cmds []tea.Cmd
+	if m.walking {
+		cmds = append(cmds, m.walker.next())
+	}
+	if m.readPending {
+		cmds = append(cmds, m.reading.wait())
+	}
+	if m.sizing {
+		cmds = append(cmds, m.sizer.next())
+	}
+	if m.spinning {
+		// newModel already marked the spinner as running
+		cmds = append(cmds, spinnerTick())
+	}
+	if m.opts.timeout > 0 {
+		cmds = append(cmds, idleTick(seconds(m.opts.timeout)))
+	}
+	return tea.Batch(cmds...)
+}
+
+// canModify reports whether path is a real subfolder that create, rename,
+// archive and delete may act on, rather than the [current] or .. entry.
+func (m model) canModify(path string) bool {
+	return path != m.root && !isRoot(path) && path != filepath.Dir(m.root)
+}
+
+// topNotice explains, for the status line, why there's no going up from
+// the current folder.
+func (m model) topNotice() string {
+	if isRoot(m.root) {
+		return "\033[90malready at the filesystem root\033[0m"
+	}
+	return "\033[90malready at the top of " + abbreviateHome(m.boundary()) + "\033[0m"
+}
+
+// parentDir returns the folder above the current one, and false at the
+// filesystem root or the --root boundary.
+func (m model) parentDir() (string, bool) {
+	parent := filepath.Dir(m.root)
+	if parent == m.root || !m.inBoundary(parent) {
+		return "", false
+	}
+	return parent, true
+}
+
+// inBoundary reports whether dir may be visited given the --root boundary.
+func (m model) inBoundary(dir string) bool {
+	b := m.boundary()
+	return b == "" || isWithin(dir, b)
+}
+
+// reload re-reads the current folder. In --leaves mode it restarts the
+// background walk and returns the command that streams its results.
+func (m *model) reload() tea.Cmd {
+	m.walker.stop()
+	m.walking = false
+	m.sizer.stop()
+	m.sizing = false
+	m.readPending = false
+	m.readTimedOut = false
+	m.denied = false
+	m.applyLocalConfig()
+
+	m.entries = nil
+	// --no-stub leaves selecting the current folder to its key alone
+	if !m.opts.noStub {
+		current := currentEntry(m.root)
+		if info, err := os.Stat(m.root); err == nil {
+			current.modTime = info.ModTime()
+		}
+		m.entries = append(m.entries, current)
+	}
+	// With --show-parent, a .. entry follows [current] except at the root
+	if parent, ok := m.parentDir(); m.opts.showParent && ok {
+		m.entries = append(m.entries, item{name: "..", path: parent})
+	}
+	m.pinned = len(m.entries)
+	m.rematch()
+
+	// With --manifest, a manifest in the folder replaces the listing
+	m.manifest = ""
+	m.skipped = skipCounts{}
+	if m.opts.manifest {
+		var items []item
+		if items, m.manifest = loadManifest(m.root, m.errLog); m.manifest != "" {
+			m.entries = append(m.entries, items...)
+			m.resort()
+			return m.startSizing()
+		}
+	}
+
+	if !m.walks() && m.opts.readTimeout > 0 {
+		// Read in the background so a slow mount can't freeze the picker
+		m.reading = startDirRead(m.reading.id+1, m.root, m.listFilter(), m.errLog, m.opts, seconds(m.opts.readTimeout))
+		if l, ok := m.reading.poll(readGrace); ok {
+			m.setListing(l)
+			return m.startSizing()
+		}
+		m.readPending = true
+		return tea.Batch(m.reading.wait(), m.startSpinner())
+	}
+	if !m.walks() {
+		m.setListing(readListing(m.root, m.listFilter(), m.errLog, m.opts))
+		return m.startSizing()
+	}
+	if m.opts.repos {
+		m.walker = startRepoWalk(m.walker.id+1, m.root, m.sort, m.listFilter(), m.opts.symlinkLoop, m.errLog, m.opts.maxDepth)
+	} else if m.opts.depth > 0 {
+		m.walker = startTreeWalk(m.walker.id+1, m.root, m.sort, m.listFilter(), m.opts.symlinkLoop, m.errLog, m.opts.depth)
+	} else {
+		m.walker = startLeafWalk(m.walker.id+1, m.root, m.sort, m.listFilter(), m.opts.symlinkLoop, m.errLog)
+	}
+	m.walking = true
+	m.walkProgress = walkProgress{}
+	return tea.Batch(m.walker.next(), m.startSpinner())
+}
+
+// walks reports whether folders are found by walking the tree below the
+// current folder, with --leaves, --repos or --depth, rather than listed.
+func (m model) walks() bool {
+	return m.opts.leaves || m.opts.repos || m.opts.depth > 0
+}
+
+// setListing adds a folder read to the entries.
+func (m *model) setListing(l listing) {
+	m.entries = append(m.entries, l.items...)
+	m.skipped = l.skipped
+	m.denied = errors.Is(l.err, fs.ErrPermission)
+	m.errLog.add(m.root, l.err)
+	m.resort()
+}
+
+// resort orders the entries after the pinned [current] and .. entries,
+// which always stay at the top whatever the sort mode.
+func (m *model) resort() {
+	sortEntries(m.entries[m.pinned:], m.sort)
+	if m.reverse {
+		slices.Reverse(m.entries[m.pinned:])
+	}
+	m.rematch()
+}
+
+// rememberFilter adds the filter to the history when it's used to open
+// or select a folder.
+func (m *model) rememberFilter() {
+	if strings.TrimSpace(m.filter) == "" {
+		return
+	}
+	m.history.add(m.filter)
+	if m.opts.saveHistory {
+		if err := m.history.save(historyPath()); err != nil {
+			m.errLog.add(historyPath(), err)
+			m.notice = "\033[31mCouldn't save the filter history: " + err.Error() + "\033[0m"
+		}
+	}
+}
+
+// recallFilter shows a filter from the history, if there was one.
+func (m *model) recallFilter(filter string, ok bool) {
+	if !ok {
+		return
+	}
+	m.setFilter(filter)
+	m.cursor = m.bestMatch()
+	m.offset = 0
+	m.fixScroll()
+}
+
+// placeCursor moves the cursor to the entry for path, as after creating
+// or renaming a folder. While a --read-timeout read is still running, the
+// cursor moves once the read finishes.
+func (m *model) placeCursor(path string) {
+	if m.readPending {
+		m.cursorTarget = path
+		return
+	}
+	for i, it := range m.filtered() {
+		if it.path == path {
+			m.cursor = i
+			m.fixScroll()
+			return
+		}
+	}
+}
+
+// nameTaken reports whether renaming target to path would collide with
+// another folder. A path that is target itself under another case, on a
+// case-insensitive filesystem, doesn't count.
+func nameTaken(path, target string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	self, err := os.Lstat(target)
+	return err != nil || !os.SameFile(info, self)
+}
+
+// refresh re-reads the current folder, keeping the filter and the cursor
+// on the same folder if it still exists.
+func (m *model) refresh() tea.Cmd {
+	// Measure the folders again too, with --du
+	for _, it := range m.entries {
+		delete(m.sizes, it.path)
+	}
+	var current string
+	if filtered := m.filtered(); len(filtered) > 0 {
+		current = filtered[m.cursor].path
+	}
+	cmd := m.reload()
+	filtered := m.filtered()
+	m.cursor = min(m.cursor, max(0, len(filtered)-1))
+	for i, it := range filtered {
+		if it.path == current {
+			m.cursor = i
+			break
+		}
+	}
+	m.fixScroll()
+	m.notice = "\033[90mrefreshed\033[0m"
+	return cmd
+}
+
+// reorder resorts the listing after the sort settings change, keeping the
+// cursor on the same folder.
+func (m *model) reorder() {
+	var current string
+	if filtered := m.filtered(); len(filtered) > 0 {
+		current = filtered[m.cursor].path
+	}
+	m.resort()
+	m.cursor = 0
+	m.offset = 0
+	for i, it := range m.filtered() {
+		if it.path == current {
+			m.cursor = i
+			m.fixScroll()
+			break
+		}
+	}
+}
+
+// descend follows dir down through folders whose only visible entry is a
+// single subfolder, as with Java package paths, when --auto-descend is set.
+// It stops at the --root boundary and on reaching a folder twice through
+// symlinks.
+func (m model) descend(dir string) string {
+	if !m.opts.autoDescend {
+		return dir
+	}
+	filter := m.listFilter()
+	seen := make(map[string]bool)
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		seen[real] = true
+	}
+	for {
+		entries, _ := readDir(dir)
+		visible := 0
+		for _, e := range entries {
+			if !filter.skip(e.Name()) {
+				visible++
 			}
 		}
-		if !isDir {
-			continue
+		dirs, _ := listDirs(dir, filter)
+		if visible != 1 || len(dirs) != 1 {
+			return dir
+		}
+		next := filepath.Join(dir, dirs[0].Name())
+		real, err := filepath.EvalSymlinks(next)
+		if err != nil || seen[real] || !m.inBoundary(next) {
+			return dir
 		}
-		dirs = append(dirs, e.Name())
-		dirMap[e.Name()] = filepath.Join(root, e.Name())
+		seen[real] = true
+		dir = next
 	}
+}
 
-	sort.Strings(dirs)
-	for _, d := range dirs {
-		items = append(items, d)
-		paths = append(paths, dirMap[d])
+// changeDir navigates to dir with a fresh listing. When dir is an ancestor
+// of the folder we came from, the cursor lands on the child leading back to it.
+// The filter is cleared unless --sticky-filter is set.
+func (m *model) changeDir(dir string) tea.Cmd {
+	if !m.inBoundary(dir) {
+		return nil
+	}
+	previousFolder := m.root
+	m.root = dir
+	if !m.opts.stickyFilter {
+		m.setFilter("")
+	}
+	cmd := m.reload()
+	m.cursor = m.bestMatch()
+	m.offset = 0
+	m.fixScroll()
+
+	if previousFolder == dir || !isWithin(previousFolder, dir) {
+		return cmd
+	}
+	rel, err := filepath.Rel(dir, previousFolder)
+	if err != nil {
+		return cmd
+	}
+	child := filepath.Join(dir, strings.Split(rel, string(filepath.Separator))[0])
+	// Select the folder we came from
+	for i, it := range m.filtered() {
+		if it.path == child {
+			m.cursor = i
+			m.fixScroll()
+			break
+		}
 	}
-	return items, paths
+	return cmd
 }
 
-func (m model) Init() tea.Cmd { return nil }
+// changeToSibling opens the folder next to the current one, in the order
+// the parent's listing would show them: the previous one for dir -1, the
+// next for +1. With --wrap-siblings it goes round from the last to the
+// first; otherwise it does nothing at the ends. At the filesystem root or the --root boundary
+// there are no siblings, and the status line says so.
+func (m *model) changeToSibling(dir int) tea.Cmd {
+	parent, ok := m.parentDir()
+	if !ok {
+		m.notice = m.topNotice()
+		return nil
+	}
+	// The siblings are ordered as the parent's listing would be, with the
+	// sizes measured so far for --du
+	siblings, _, _ := loadDir(parent, m.listFilter(), m.errLog)
+	isCurrent := func(it item) bool { return it.path == m.root }
+	if !slices.ContainsFunc(siblings, isCurrent) {
+		// Listed even if it's normally skipped
+		current := item{name: filepath.Base(m.root), path: m.root, order: -1}
+		if info, err := os.Stat(m.root); err == nil {
+			current.modTime = info.ModTime()
+		}
+		siblings = append(siblings, current)
+	}
+	for i := range siblings {
+		if size, ok := m.sizes[siblings[i].path]; ok {
+			siblings[i].size = &size
+		}
+	}
+	sortEntries(siblings, m.sort)
+	if m.reverse {
+		slices.Reverse(siblings)
+	}
+	i := slices.IndexFunc(siblings, isCurrent) + dir
+	if i < 0 || i >= len(siblings) {
+		if !m.opts.wrapSiblings {
+			return nil
+		}
+		i = (i + len(siblings)) % len(siblings)
+	}
+	if isCurrent(siblings[i]) {
+		return nil
+	}
+	cmd := m.changeDir(siblings[i].path)
+	m.setFilter("")
+	m.cursor = m.bestMatch()
+	m.fixScroll()
+	return cmd
+}
+
+// followPath handles a / typed with --path-filter, like completing a path
+// in the shell: the filter so far names a folder relative to the current
+// one, which is opened with an empty filter for the next component. ".."
+// goes up a level and "." stays put. ok is false when no folder has that
+// name, and the / is then filtered on like any other character.
+func (m *model) followPath() (tea.Cmd, bool) {
+	var dir string
+	switch name := m.filter; name {
+	case "":
+		return nil, false
+	case ".":
+		dir = m.root
+	case "..":
+		parent, ok := m.parentDir()
+		if !ok {
+			return nil, false
+		}
+		dir = parent
+	default:
+		var ok bool
+		if dir, ok = m.childNamed(name); !ok {
+			return nil, false
+		}
+	}
+	if !m.inBoundary(dir) {
+		return nil, false
+	}
+	var cmd tea.Cmd
+	if dir != m.root {
+		cmd = m.changeDir(dir)
+	}
+	m.setFilter("")
+	m.history.reset()
+	m.cursor = m.bestMatch()
+	m.offset = 0
+	m.fixScroll()
+	return cmd, true
+}
 
+// childNamed returns the subfolder of the current folder called name.
+// An exact match wins; otherwise a single case-insensitive match is used.
+func (m model) childNamed(name string) (string, bool) {
+	dir := filepath.Join(m.root, name)
+	if info, err := os.Stat(dir); err == nil && info.IsDir() && filepath.Dir(dir) == m.root {
+		return dir, true
+	}
+	dirs, _ := listDirs(m.root, m.listFilter())
+	found := ""
+	for _, d := range dirs {
+		if strings.EqualFold(d.Name(), name) {
+			if found != "" {
+				return "", false
+			}
+			found = filepath.Join(m.root, d.Name())
+		}
+	}
+	return found, found != ""
+}
+
+// fixScroll keeps the cursor's row inside the viewport, with up to
+// --scroll-margin rows of context above and below it. The offset counts
+// rows, which are single items unless the list is drawn in columns.
 func (m *model) fixScroll() {
 	visible := m.visibleLines()
-	if m.cursor < m.offset {
-		m.offset = m.cursor
+	row := m.cursorRow()
+	// A margin over half the viewport would leave the cursor nowhere to go
+	margin := max(0, min(m.opts.scrollMargin, (visible-1)/2))
+	if row-margin < m.offset {
+		m.offset = row - margin
+	}
+	if row+margin >= m.offset+visible {
+		m.offset = row + margin - visible + 1
 	}
-	if m.cursor >= m.offset+visible {
-		m.offset = m.cursor - visible + 1
+	// Near the ends of the list the margin can't be kept
+	m.offset = max(0, min(m.offset, m.gridRows()-visible))
+}
+
+// keepPlace scrolls after a resize so the cursor stays about as far down
+// the list as it was: place rows from the top of visible rows before.
+// fixScroll then clamps the offset at the ends of the list.
+func (m *model) keepPlace(visible, place int) {
+	now := m.visibleLines()
+	if visible <= 1 || now <= 1 {
+		return
 	}
+	place = max(0, min(place, visible-1))
+	m.offset = m.cursorRow() - (place*(now-1)+(visible-1)/2)/(visible-1)
 }
 
 func (m model) visibleLines() int {
-	// Reserve lines for: path, filter, empty, scroll indicator, help
-	reserved := 5
-	if m.height <= reserved {
-		return 5 // smaller fallback
+	if m.height <= 0 {
+		return 5 // size not known yet
 	}
-	return m.height - reserved
+	// At least one row, even when the fixed lines fill the terminal
+	return max(1, m.height-m.reservedLines())
+}
+
+// reservedLines counts the non-item lines View will render.
+func (m model) reservedLines() int {
+	return m.extraLines().reserved()
+}
+
+// extraLines records which optional lines View draws besides the items.
+type extraLines struct {
+	hint   bool // empty-state hint below the items
+	more   bool // "...and N more" when --max-results truncates the matches
+	scroll bool // scroll indicator, only when the list doesn't fit
+	status bool // status line above the footer
+}
+
+// extraLines works out the optional lines to draw. On a short terminal
+// they give way, least useful first, so a row of folders still fits.
+func (m model) extraLines() extraLines {
+	e := extraLines{
+		hint:   m.emptyState() != "",
+		more:   m.truncated() > 0,
+		status: m.statusLine() != "",
+	}
+	if m.height <= 0 {
+		e.scroll = m.gridRows() > m.visibleLines() // size not known yet
+		return e
+	}
+	e.scroll = m.gridRows() > m.height-e.reserved()
+	for _, shown := range []*bool{&e.scroll, &e.more, &e.status, &e.hint} {
+		if m.height > e.reserved() {
+			break
+		}
+		*shown = false
+	}
+	return e
+}
+
+// reserved counts the non-item lines: the path, the filter (or an error),
+// the empty line and the footer are always shown.
+func (e extraLines) reserved() int {
+	n := 4
+	for _, shown := range []bool{e.hint, e.more, e.scroll, e.status} {
+		if shown {
+			n++
+		}
+	}
+	return n
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Any key or click puts off --timeout
+	switch msg.(type) {
+	case tea.KeyMsg, tea.MouseMsg:
+		m.lastKey = time.Now()
+	}
+	next, cmd := m.update(msg)
+	n := next.(model)
+	if n.opts.loop {
+		cmd = n.keepPicking(cmd)
+	}
+	if vlog != nil {
+		logChanges(m, n)
+	}
+	if n.opts.animate {
+		cmd = tea.Batch(cmd, n.animateCount())
+	}
+	return n, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
+		// The first size only sets up the screen; later ones are resizes
+		resized := m.height > 0
+		visible, place := m.visibleLines(), m.cursorRow()-m.offset
 		m.height = msg.Height
+		// --height caps the rows used on tall terminals
+		if m.opts.height > 0 {
+			m.height = min(msg.Height, m.opts.height)
+		}
+		m.width = msg.Width
+		if resized {
+			m.keepPlace(visible, place)
+		}
+		// The number of columns may have changed
+		m.fixScroll()
+		return m, nil
+	case countMsg:
+		return m, m.stepCount()
+	case idleMsg:
+		return m.checkIdle()
+	case spinnerMsg:
+		// Stop ticking once there's no background work left
+		if !m.busy() {
+			m.spinning = false
+			return m, nil
+		}
+		m.spinFrame = (m.spinFrame + 1) % len(spinnerFrames)
+		m.walkProgress = m.walker.progress()
+		return m, spinnerTick()
+	case dirReadMsg:
+		if msg.id != m.reading.id || !m.readPending {
+			return m, nil // superseded by navigating elsewhere
+		}
+		m.readPending = false
+		if msg.timedOut {
+			m.readTimedOut = true
+			m.errLog.add(m.reading.path, timeoutError(seconds(m.opts.readTimeout)))
+			return m, nil
+		}
+		m.setListing(msg.listing)
+		m.cursor = m.bestMatch()
+		m.fixScroll()
+		if path := m.cursorTarget; path != "" {
+			m.cursorTarget = ""
+			m.placeCursor(path)
+		}
+		return m, m.startSizing()
+	case sizeMsg:
+		if msg.id != m.sizer.id {
+			return m, nil // superseded by a newer listing
+		}
+		if msg.done {
+			m.sizing = false
+			return m, nil
+		}
+		m.setSize(msg.path, msg.size)
+		return m, m.sizer.next()
+	case walkMsg:
+		if msg.id != m.walker.id {
+			return m, nil // superseded by a newer walk
+		}
+		// The first folders found get the cursor with --cursor-start first
+		first := len(m.entries) == m.pinned && m.cursor == 0 && m.filter == ""
+		var current string
+		if filtered := m.filtered(); len(filtered) > 0 {
+			current = filtered[m.cursor].path
+		}
+		// Batches come in walk order; sort them in, keeping the cursor on
+		// the folder it was on
+		m.entries = append(m.entries, msg.items...)
+		m.resort()
+		if first {
+			m.cursor = m.startCursor()
+		} else {
+			m.placeCursor(current)
+		}
+		if msg.done {
+			// Freeze the final totals
+			m.walkProgress = m.walker.progress()
+			m.walking = false
+			logf("walk", "path=%q leaves=%d", m.root, len(m.entries)-m.pinned)
+			return m, nil
+		}
+		return m, m.walker.next()
+	case revealMsg:
+		m.errLog.add(msg.path, msg.err)
+		m.notice = revealNotice(msg)
+		return m, nil
+	case clipboardMsg:
+		m.errLog.add("clipboard", msg.err)
+		m.notice = clipboardNotice(msg)
+		return m, nil
+	case tea.MouseMsg:
+		// Clicking a path segment in the header jumps to that folder
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft && msg.Y == m.headerRow() && !m.overlayActive() {
+			if i := m.crumbAt(msg.X); i >= 0 {
+				m.crumbMode = false
+				return m, m.changeDir(m.crumbs()[i].path)
+			}
+		}
 		return m, nil
 	case tea.KeyMsg:
 		k := msg.String()
@@ -135,6 +1020,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				home, _ := os.UserHomeDir()
 				archiveDir := filepath.Join(home, "Dev-Archive")
 				if err := os.MkdirAll(archiveDir, 0755); err != nil {
+					m.errLog.add(archiveDir, err)
 					m.archiveError = "Error creating archive dir: " + err.Error()
 					m.confirmArchive = false
 					m.archiveTarget = ""
@@ -145,19 +1031,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				destPath := filepath.Join(archiveDir, folderName)
 				err := os.Rename(m.archiveTarget, destPath)
 				if err != nil {
+					m.errLog.add(m.archiveTarget, err)
 					m.archiveError = "Error: " + err.Error()
 					m.confirmArchive = false
 					m.archiveTarget = ""
 					return m, nil
 				}
 				// Refresh the current directory
-				m.items, m.paths = loadDir(m.root)
-				m.cursor = 0
+				cmd := m.reload()
+				m.cursor = m.bestMatch()
 				m.offset = 0
 				m.confirmArchive = false
 				m.archiveTarget = ""
 				m.archiveError = ""
-				return m, nil
+				return m, cmd
 			case "n", "N", "esc":
 				m.confirmArchive = false
 				m.archiveTarget = ""
@@ -172,22 +1059,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.confirmDelete {
 			switch k {
 			case "y", "Y":
-				// Delete the folder
-				err := os.RemoveAll(m.deleteTarget)
+				// Delete the folder, or move it to the trash with --trash
+				var err error
+				if m.trashing() {
+					err = moveToTrash(m.deleteTarget)
+				} else {
+					err = os.RemoveAll(m.deleteTarget)
+				}
 				if err != nil {
+					m.errLog.add(m.deleteTarget, err)
 					m.deleteError = "Error: " + err.Error()
 					m.confirmDelete = false
 					m.deleteTarget = ""
 					return m, nil
 				}
 				// Refresh the current directory
-				m.items, m.paths = loadDir(m.root)
-				m.cursor = 0
+				cmd := m.reload()
+				m.cursor = m.bestMatch()
 				m.offset = 0
 				m.confirmDelete = false
 				m.deleteTarget = ""
 				m.deleteError = ""
-				return m, nil
+				return m, cmd
 			case "n", "N", "esc":
 				m.confirmDelete = false
 				m.deleteTarget = ""
@@ -206,26 +1099,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					newPath := filepath.Join(m.root, m.newFolderName)
 					err := os.Mkdir(newPath, 0755)
 					if err != nil {
+						m.errLog.add(newPath, err)
 						m.createError = "Error: " + err.Error()
 						m.createMode = false
 						m.newFolderName = ""
 						return m, nil
 					}
 					// Refresh and select the new folder
-					m.items, m.paths = loadDir(m.root)
+					cmd := m.reload()
 					m.cursor = 0
 					m.offset = 0
-					// Find and select the new folder
-					for i, p := range m.paths {
-						if p == newPath {
-							m.cursor = i
-							m.fixScroll()
-							break
-						}
-					}
+					m.placeCursor(newPath)
 					m.createMode = false
 					m.newFolderName = ""
 					m.createError = ""
+					return m, cmd
 				}
 				return m, nil
 			case "esc":
@@ -247,6 +1135,99 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// Handle rename folder mode
+		if m.renameMode {
+			switch k {
+			case "enter":
+				if m.renameName == "" {
+					return m, nil
+				}
+				if strings.ContainsRune(m.renameName, os.PathSeparator) {
+					m.renameError = "Name cannot contain \"" + string(os.PathSeparator) + "\""
+					return m, nil
+				}
+				// Walks list nested folders, which stay where they are
+				newPath := filepath.Join(filepath.Dir(m.renameTarget), m.renameName)
+				if newPath == m.renameTarget {
+					m.renameMode = false
+					m.renameTarget = ""
+					m.renameName = ""
+					m.renameError = ""
+					return m, nil
+				}
+				// Stay in input mode on errors so the name can be corrected.
+				// On a case-insensitive filesystem a change of case finds
+				// the folder itself, which isn't a collision
+				if nameTaken(newPath, m.renameTarget) {
+					m.renameError = "A folder named \"" + m.renameName + "\" already exists"
+					return m, nil
+				}
+				if err := os.Rename(m.renameTarget, newPath); err != nil {
+					m.errLog.add(m.renameTarget, err)
+					m.renameError = "Error: " + err.Error()
+					return m, nil
+				}
+				// Refresh and keep the cursor on the renamed folder
+				m.setFilter("")
+				cmd := m.reload()
+				m.cursor = 0
+				m.offset = 0
+				m.placeCursor(newPath)
+				m.renameMode = false
+				m.renameTarget = ""
+				m.renameName = ""
+				m.renameError = ""
+				return m, cmd
+			case "esc":
+				m.renameMode = false
+				m.renameTarget = ""
+				m.renameName = ""
+				m.renameError = ""
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			case "backspace":
+				// The name starts as the folder's own, which may not be ASCII
+				_, size := utf8.DecodeLastRuneInString(m.renameName)
+				m.renameName = m.renameName[:len(m.renameName)-size]
+				return m, nil
+			default:
+				if utf8.RuneCountInString(k) == 1 && k >= " " {
+					m.renameName += k
+				}
+				return m, nil
+			}
+		}
+
+		// Handle breadcrumb navigation mode
+		if m.crumbMode {
+			crumbs := m.crumbs()
+			switch k {
+			case "left":
+				if m.crumbCursor > 0 {
+					m.crumbCursor--
+				}
+			case "right":
+				if m.crumbCursor < len(crumbs)-1 {
+					m.crumbCursor++
+				}
+			case "enter":
+				m.crumbMode = false
+				if target := crumbs[m.crumbCursor].path; target != m.root {
+					return m, m.changeDir(target)
+				}
+			case "esc":
+				m.crumbMode = false
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				if m.keys.action(k) == actBreadcrumb {
+					m.crumbMode = false
+				}
+			}
+			return m, nil
+		}
+
 		// Clear error messages on any key
 		if m.deleteError != "" {
 			m.deleteError = ""
@@ -257,155 +1238,360 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.archiveError != "" {
 			m.archiveError = ""
 		}
+		m.notice = ""
+
+		// Esc always closes the help screen and the errors panel
+		if (m.showHelp || m.showErrors) && k == "esc" {
+			m.showHelp = false
+			m.showErrors = false
+			return m, nil
+		}
+
+		if m.showMarks && m.keys.action(k) != actQuit {
+			return m.updateMarks(k), nil
+		}
+		if m.jumping && m.keys.action(k) != actQuit {
+			return m.updateJump(k), nil
+		}
+		if m.savingSlot && m.keys.action(k) != actQuit {
+			return m.saveSlot(k), nil
+		}
+		if m.showSlots && m.keys.action(k) != actQuit {
+			return m.updateSlots(k), nil
+		}
 
-		switch k {
-		case "ctrl+c":
+		var cmd tea.Cmd
+		switch m.keys.action(k) {
+		case actQuit:
 			return m, tea.Quit
-		case "f1":
+		case actHelp:
 			m.showHelp = !m.showHelp
+			m.showErrors = false
 			return m, nil
-		case "esc":
-			if m.showHelp {
-				m.showHelp = false
-				return m, nil
+		case actErrors:
+			m.showErrors = !m.showErrors
+			m.showHelp = false
+			return m, nil
+		case actMark:
+			if !m.opts.multi {
+				m.notice = "\033[90mstart pf with --multi to mark folders\033[0m"
+			} else if len(filtered) > 0 {
+				// Mark and move on, so several folders can be marked in a row
+				m.marks.toggle(filtered[m.cursor].path)
+				if m.cursor < len(filtered)-1 {
+					m.cursor++
+					m.fixScroll()
+				}
 			}
+		case actInvert:
+			m.inverted = !m.inverted
+			m.rematch()
+			m.cursor = m.bestMatch()
+			m.offset = 0
+			m.fixScroll()
+		case actJump:
+			if len(filtered) > 0 {
+				m.jumping = true
+				m.notice = "\033[90mjump: type the label of a folder (Esc cancels)\033[0m"
+			}
+			return m, nil
+		case actSaveSlot:
+			m.savingSlot = true
+			m.notice = "\033[90msave the filter to slot: press 1-9\033[0m"
+			return m, nil
+		case actSlots:
+			m.showSlots = true
+			m.showHelp = false
+			m.showErrors = false
+			return m, nil
+		case actMarks:
+			m.showMarks = true
+			m.showHelp = false
+			m.showErrors = false
+			m.marksCursor = 0
+			return m, nil
+		case actParent:
 			// Go to parent folder
-			parent := filepath.Dir(m.root)
-			if parent != m.root {
-				previousFolder := m.root
-				m.root = parent
-				m.filter = ""
-				m.items, m.paths = loadDir(m.root)
-				m.cursor = 0
-				m.offset = 0
-				// Select the folder we came from
-				for i, p := range m.paths {
-					if p == previousFolder {
-						m.cursor = i
-						m.fixScroll()
-						break
-					}
-				}
+			if parent, ok := m.parentDir(); ok {
+				cmd = m.changeDir(parent)
+			} else {
+				m.notice = m.topNotice()
 			}
-		case "up":
-			if m.cursor > 0 {
-				m.cursor--
-				m.fixScroll()
+		case actUp, actDown:
+			// With --bottom the list grows upward, so the keys follow the screen
+			down := m.keys.action(k) == actDown
+			if m.opts.bottom {
+				down = !down
 			}
-		case "down":
-			if m.cursor < len(filtered)-1 {
+			if down && m.cursor < len(filtered)-1 {
 				m.cursor++
 				m.fixScroll()
+			} else if !down && m.cursor > 0 {
+				m.cursor--
+				m.fixScroll()
+			}
+		case actOpen:
+			// With --enter-selects → opens, but on a list in columns it
+			// moves across them first
+			if k == "right" && m.moveColumn(1) {
+				break
 			}
-		case "enter":
 			if len(filtered) > 0 {
+				m.rememberFilter()
 				selectedPath := filtered[m.cursor].path
-				// If selecting current folder, go to parent instead
-				if selectedPath == m.root {
-					parent := filepath.Dir(m.root)
-					if parent != m.root {
-						previousFolder := m.root
-						m.root = parent
-						m.filter = ""
-						m.items, m.paths = loadDir(m.root)
-						m.cursor = 0
-						m.offset = 0
-						// Select the folder we came from
-						for i, p := range m.paths {
-							if p == previousFolder {
-								m.cursor = i
-								m.fixScroll()
-								break
-							}
-						}
-					}
+				// The current folder's entry selects it, as Tab would
+				if filtered[m.cursor].stub {
+					m.selected = selectedPath
+					return m, tea.Quit
+				}
+				// Going up, as through the .. entry, mustn't descend
+				// straight back into the folder just left
+				if isWithin(m.root, selectedPath) {
+					cmd = m.changeDir(selectedPath)
 				} else {
-					m.root = selectedPath
-					m.filter = ""
-					m.items, m.paths = loadDir(m.root)
-					m.cursor = 0
-					m.offset = 0
+					cmd = m.changeDir(m.descend(selectedPath))
 				}
 			}
-		case "tab":
+		case actSelect:
+			// With folders marked, Tab selects all of them instead
+			if len(m.marks.paths) > 0 {
+				m.rememberFilter()
+				m.picked = m.marks.paths
+				return m, tea.Quit
+			}
 			if len(filtered) > 0 {
+				m.rememberFilter()
 				m.selected = filtered[m.cursor].path
 				return m, tea.Quit
 			}
-		case "alt+backspace", "ctrl+backspace":
+		case actSelectHere:
+			m.selected = m.root
+			return m, tea.Quit
+		case actDelete:
 			// Delete folder - show confirmation
 			if len(filtered) > 0 {
 				selectedPath := filtered[m.cursor].path
-				// Don't allow deleting the current folder indicator or root
-				if selectedPath != m.root && selectedPath != "/" {
+				// Don't allow deleting the current folder indicator, .. or root,
+				// and say so for a protected folder
+				switch {
+				case !m.canModify(selectedPath):
+				case m.isProtected(selectedPath):
+					m.notice = protectedNotice(selectedPath)
+				default:
 					m.confirmDelete = true
 					m.deleteTarget = selectedPath
 				}
 			}
-		case "ctrl+n":
+		case actPrevSib:
+			cmd = m.changeToSibling(-1)
+		case actNextSib:
+			cmd = m.changeToSibling(1)
+		case actBreadcrumb:
+			// Breadcrumb mode - pick an ancestor folder from the path header
+			m.crumbMode = true
+			m.crumbCursor = len(m.crumbs()) - 1
+		case actSort:
+			m.sort = m.sort.next()
+			// Size order needs --du
+			if m.sort == sortSize && !m.opts.du {
+				m.sort = m.sort.next()
+			}
+			m.reorder()
+		case actReverse:
+			m.reverse = !m.reverse
+			m.reorder()
+		case actRefresh:
+			cmd = m.refresh()
+		case actFullPath:
+			// Toggle between ~ and the absolute path in the header
+			m.fullPath = !m.fullPath
+		case actCreate:
 			// Create new folder
 			m.createMode = true
 			m.newFolderName = ""
-		case "ctrl+a":
+		case actRename:
+			// Rename folder - show input prefilled with the current name
+			if len(filtered) > 0 {
+				selectedPath := filtered[m.cursor].path
+				// Don't allow renaming the current folder indicator, .. or root,
+				// and say so for a protected folder
+				switch {
+				case !m.canModify(selectedPath):
+				case m.isProtected(selectedPath):
+					m.notice = protectedNotice(selectedPath)
+				default:
+					m.renameMode = true
+					m.renameTarget = selectedPath
+					m.renameName = filepath.Base(selectedPath)
+					m.renameError = ""
+				}
+			}
+		case actReveal:
+			// Show the highlighted folder in the OS file manager
+			if len(filtered) > 0 {
+				cmd = revealFolder(filtered[m.cursor].path)
+			}
+		case actCopyCd:
+			// Copy a ready-to-paste cd command for the highlighted folder
+			if len(filtered) > 0 {
+				cmd = copyText(cdCommand(filtered[m.cursor].path))
+			}
+		case actArchive:
 			// Archive folder - move to ~/Dev-Archive
 			if len(filtered) > 0 {
 				selectedPath := filtered[m.cursor].path
-				// Don't allow archiving the current folder indicator or root
-				if selectedPath != m.root && selectedPath != "/" {
+				// Don't allow archiving the current folder indicator, .. or root,
+				// and say so for a protected folder
+				switch {
+				case !m.canModify(selectedPath):
+				case m.isProtected(selectedPath):
+					m.notice = protectedNotice(selectedPath)
+				default:
 					m.confirmArchive = true
 					m.archiveTarget = selectedPath
 				}
 			}
-		case "backspace":
-			if len(m.filter) > 0 {
-				m.filter = m.filter[:len(m.filter)-1]
-				m.cursor = 0
-				m.offset = 0
-			}
+		case actPrevFilter:
+			m.recallFilter(m.history.prev(m.filter))
+		case actNextFilter:
+			m.recallFilter(m.history.next())
 		default:
-			if len(k) == 1 && k >= " " {
-				m.filter += k
+			// Unbound keys edit the filter
+			switch {
+			case k == "backspace":
+				if len(m.filter) > 0 {
+					m.setFilter(m.filter[:len(m.filter)-1])
+					m.cursor = 0
+					m.offset = 0
+					m.history.reset()
+				}
+			case k == "." && m.filter == "" && !m.opts.pathFilter:
+				// Select the folder you're in, wherever the cursor is.
+				// With --path-filter "." starts a path like ../ instead
+				m.selected = m.root
+				return m, tea.Quit
+			case k == "left":
+				m.moveColumn(-1)
+			case k == "right":
+				m.moveColumn(1)
+			case k == "ctrl+u":
+				// Clear the whole filter; the history is kept
+				m.setFilter("")
 				m.cursor = 0
 				m.offset = 0
+				m.history.reset()
+			case m.opts.pathFilter && (k == "/" || k == string(filepath.Separator)):
+				if c, ok := m.followPath(); ok {
+					cmd = c
+					break
+				}
+				m.setFilter(m.filter + k)
+				m.history.reset()
+				m.cursor = m.bestMatch()
+				m.offset = 0
+				m.fixScroll()
+			case len(k) == 1 && k >= " ":
+				m.setFilter(m.filter + k)
+				m.history.reset()
+				// Jump to the best match instead of the top of the list
+				m.cursor = m.bestMatch()
+				m.offset = 0
+				m.fixScroll()
 			}
 		}
+		return m, cmd
 	}
 	return m, nil
 }
 
 type item struct {
-	name string
-	path string
+	name    string
+	path    string
+	modTime time.Time // zero when the folder's info couldn't be read
+	order   int       // position as read from disk, for the none sort mode
+	mount   bool      // on another device than its parent, with --mounts
+	stub    bool      // the entry for the current folder itself
+	size    *dirSize  // total file size with --du, nil until measured
+	link    string    // where a symlinked folder points, with --link-targets
+	perms   string    // permissions and owner, with --long
 }
 
+// filtered returns the matches for the current filter, capped by --max-results.
 func (m model) filtered() []item {
-	var result []item
-	f := strings.ToLower(strings.TrimSpace(m.filter))
+	result := m.matched
+	if m.opts.maxResults > 0 && len(result) > m.opts.maxResults {
+		return result[:m.opts.maxResults]
+	}
+	return result
+}
 
-	if f == "" {
-		for i, name := range m.items {
-			result = append(result, item{name, m.paths[i]})
-		}
-		return result
+// truncated returns how many matches --max-results is hiding.
+func (m model) truncated() int {
+	if m.opts.maxResults <= 0 {
+		return 0
 	}
+	if n := len(m.matched) - m.opts.maxResults; n > 0 {
+		return n
+	}
+	return 0
+}
 
-	// Split filter into words - ALL words must match
-	words := strings.Fields(f)
+// setFilter changes the filter and the matches with it.
+func (m *model) setFilter(filter string) {
+	m.filter = filter
+	m.rematch()
+}
 
-	for i, name := range m.items {
-		nameLower := strings.ToLower(name)
-		allMatch := true
-		for _, word := range words {
-			if !strings.Contains(nameLower, word) {
-				allMatch = false
-				break
+// rematch brings m.matched up to date after the entries, the filter or
+// the way it matches change, so drawing the list doesn't match again.
+func (m *model) rematch() {
+	m.matched = m.matches()
+}
+
+// matches ranks the entries against the filter.
+func (m model) matches() []item {
+	var result []item
+
+	// Split filter into words - ALL words must match, each one by --match
+	exactCase := m.opts.smartCase && smartCase(m.filter)
+	words := filterWords(m.filter, exactCase)
+	matcher := m.matcher()
+	if len(words) == 0 {
+		return slices.Clone(m.entries)
+	}
+
+	// Pinned entries stay on top; the rest are ranked by score
+	var ranked []scoredItem
+	for i, it := range m.entries {
+		match, ok := scoreWords(matchText(it), words, matcher, exactCase)
+		// Inverted, the non-matches are listed in their usual order
+		if m.inverted {
+			if !ok || i < m.pinned {
+				result = append(result, it)
 			}
+			continue
 		}
-		if allMatch {
-			result = append(result, item{name, m.paths[i]})
+		// With --keep-stub the current folder's entry survives any filter
+		if !ok && !(it.stub && m.opts.keepStub) {
+			continue
+		}
+		if i < m.pinned {
+			result = append(result, it)
+		} else {
+			match.item = it
+			ranked = append(ranked, match)
 		}
 	}
-	return result
+	return append(result, rankMatches(ranked, m.opts.rank)...)
+}
+
+// matcher returns the matching algorithm in effect: a .pf file's, or
+// --match.
+func (m model) matcher() string {
+	if m.local.match != "" {
+		return m.local.match
+	}
+	return m.opts.match
 }
 
 func (m model) helpView() string {
@@ -413,45 +1599,58 @@ func (m model) helpView() string {
 	lines = append(lines, "")
 	lines = append(lines, "  \033[1;34mpf - folder picker\033[0m  \033[90mv"+version+"\033[0m")
 	lines = append(lines, "")
-	lines = append(lines, "  \033[1m↑ / ↓\033[0m       Navigate list")
-	lines = append(lines, "  \033[1mEnter\033[0m       Open folder")
-	lines = append(lines, "  \033[1mTab\033[0m         Select & cd to folder")
-	lines = append(lines, "  \033[1mEsc\033[0m         Go to parent folder")
-	lines = append(lines, "  \033[1mBackspace\033[0m   Clear filter character")
-	lines = append(lines, "  \033[1mCtrl+N\033[0m      Create new folder")
-	lines = append(lines, "  \033[1mCtrl+A\033[0m      Archive folder (~/Dev-Archive)")
-	lines = append(lines, "  \033[1mAlt+⌫\033[0m       Delete selected folder")
-	lines = append(lines, "  \033[1mCtrl+C\033[0m      Quit without select")
-	lines = append(lines, "  \033[1mF1\033[0m          Toggle this help")
+	lines = append(lines, m.keys.helpLines()...)
 	lines = append(lines, "")
 	lines = append(lines, "  \033[90mType any text to filter folders")
+	if m.matcher() == matcherSubstring {
+		lines = append(lines, "  Each word must appear whole in the name")
+	} else {
+		lines = append(lines, "  Letters match in order, gaps allowed")
+	}
 	lines = append(lines, "  Multiple words = match all\033[0m")
 	lines = append(lines, "")
-	lines = append(lines, "  \033[90mPress Esc or F1 to close\033[0m")
+	lines = append(lines, "  \033[90mPress Esc or "+m.keys.label(actHelp)+" to close\033[0m")
 	lines = append(lines, "")
 	lines = append(lines, "  \033[90mhttps://pf.pm7.dev\033[0m")
 	lines = append(lines, "")
 	return strings.Join(lines, "\n")
 }
 
+// trashing reports whether delete moves folders to the trash.
+func (m model) trashing() bool {
+	return m.opts.trash && trashDir() != ""
+}
+
 func (m model) confirmDeleteView() string {
 	var lines []string
 	lines = append(lines, "")
-	lines = append(lines, "  \033[1;31mDelete folder?\033[0m")
+	if m.trashing() {
+		lines = append(lines, "  \033[1;33mMove folder to trash?\033[0m")
+	} else {
+		lines = append(lines, "  \033[1;31mDelete folder?\033[0m")
+	}
 	lines = append(lines, "")
 
 	// Show the folder path nicely
-	home, _ := os.UserHomeDir()
-	displayPath := m.deleteTarget
-	if strings.HasPrefix(displayPath, home) {
-		displayPath = "~" + displayPath[len(home):]
-	}
+	displayPath := abbreviateHome(m.deleteTarget)
 	lines = append(lines, "  \033[1m"+displayPath+"\033[0m")
 	lines = append(lines, "")
-	lines = append(lines, "  \033[90mThis will permanently delete the folder")
-	lines = append(lines, "  and all its contents!\033[0m")
-	lines = append(lines, "")
-	lines = append(lines, "  \033[48;5;236m\033[97m y = delete • n/Esc = cancel \033[0m")
+	switch {
+	case m.trashing():
+		lines = append(lines, "  \033[90mThe folder can be restored from "+abbreviateHome(trashDir())+".\033[0m")
+		lines = append(lines, "")
+		lines = append(lines, "  \033[48;5;236m\033[97m y = trash • n/Esc = cancel \033[0m")
+	case m.opts.trash:
+		lines = append(lines, "  \033[31mNo trash is available on this system, so this")
+		lines = append(lines, "  will permanently delete the folder and all its contents!\033[0m")
+		lines = append(lines, "")
+		lines = append(lines, "  \033[48;5;236m\033[97m y = delete permanently • n/Esc = cancel \033[0m")
+	default:
+		lines = append(lines, "  \033[90mThis will permanently delete the folder")
+		lines = append(lines, "  and all its contents!\033[0m")
+		lines = append(lines, "")
+		lines = append(lines, "  \033[48;5;236m\033[97m y = delete • n/Esc = cancel \033[0m")
+	}
 	lines = append(lines, "")
 	return strings.Join(lines, "\n")
 }
@@ -463,11 +1662,7 @@ func (m model) createFolderView() string {
 	lines = append(lines, "")
 
 	// Show current path
-	home, _ := os.UserHomeDir()
-	displayPath := m.root
-	if strings.HasPrefix(displayPath, home) {
-		displayPath = "~" + displayPath[len(home):]
-	}
+	displayPath := abbreviateHome(m.root)
 	lines = append(lines, "  \033[90min "+displayPath+"/\033[0m")
 	lines = append(lines, "")
 
@@ -479,6 +1674,28 @@ func (m model) createFolderView() string {
 	return strings.Join(lines, "\n")
 }
 
+func (m model) renameFolderView() string {
+	var lines []string
+	lines = append(lines, "")
+	lines = append(lines, "  \033[1;33mRename folder\033[0m")
+	lines = append(lines, "")
+
+	// Show the folder being renamed
+	displayPath := abbreviateHome(m.renameTarget)
+	lines = append(lines, "  \033[90m"+displayPath+"\033[0m")
+	lines = append(lines, "")
+
+	// Show input field
+	lines = append(lines, "  \033[1mName: "+m.renameName+"_\033[0m")
+	if m.renameError != "" {
+		lines = append(lines, "  \033[31m"+m.renameError+"\033[0m")
+	}
+	lines = append(lines, "")
+	lines = append(lines, "  \033[48;5;236m\033[97m Enter = rename • Esc = cancel \033[0m")
+	lines = append(lines, "")
+	return strings.Join(lines, "\n")
+}
+
 func (m model) confirmArchiveView() string {
 	var lines []string
 	lines = append(lines, "")
@@ -486,11 +1703,7 @@ func (m model) confirmArchiveView() string {
 	lines = append(lines, "")
 
 	// Show the source folder path
-	home, _ := os.UserHomeDir()
-	displayPath := m.archiveTarget
-	if strings.HasPrefix(displayPath, home) {
-		displayPath = "~" + displayPath[len(home):]
-	}
+	displayPath := abbreviateHome(m.archiveTarget)
 	lines = append(lines, "  \033[1mFrom:\033[0m "+displayPath)
 
 	// Show the destination path
@@ -504,11 +1717,295 @@ func (m model) confirmArchiveView() string {
 	return strings.Join(lines, "\n")
 }
 
+// emptyState returns a hint for when the list has no real folders to
+// show, or "" otherwise.
+func (m model) emptyState() string {
+	if m.walking || m.readPending || m.matchCount() > 0 {
+		return ""
+	}
+	if strings.TrimSpace(m.filter) != "" {
+		return "(no matches for '" + m.filter + "' — Backspace to edit)"
+	}
+	hint := "(no subfolders — "
+	if m.denied {
+		hint = "(permission denied — "
+	}
+	if m.readTimedOut {
+		hint = "(timed out — "
+	}
+	if m.opts.noStub {
+		hint += m.keys.label(actSelectHere) + " to select this folder"
+	} else {
+		hint += m.keys.label(actSelect) + " to select this folder"
+	}
+	if _, ok := m.parentDir(); ok {
+		hint += ", " + m.keys.label(actParent) + " to go back"
+	}
+	return hint + ")"
+}
+
+// bestMatch returns the cursor index of the top-ranked real folder, or 0
+// when only pinned entries match. Without a filter it's where
+// --cursor-start puts the cursor.
+func (m model) bestMatch() int {
+	if strings.TrimSpace(m.filter) == "" {
+		return m.startCursor()
+	}
+	for i, it := range m.filtered() {
+		if !m.isPinned(it.path) {
+			return i
+		}
+	}
+	return 0
+}
+
+// startCursor returns the cursor index for a fresh listing: the first
+// real folder with --cursor-start first, if there is one, else the top.
+func (m model) startCursor() int {
+	mode := m.opts.cursorStart
+	if m.local.cursor != "" {
+		mode = m.local.cursor
+	}
+	if mode == cursorFirst && m.pinned < len(m.entries) {
+		return m.pinned
+	}
+	return 0
+}
+
+// isPinned reports whether path belongs to the [current] or .. entry.
+func (m model) isPinned(path string) bool {
+	return slices.ContainsFunc(m.entries[:m.pinned], func(p item) bool { return p.path == path })
+}
+
+// matchCount returns how many real folders match the filter, leaving out
+// the pinned [current] and .. entries.
+func (m model) matchCount() int {
+	n := 0
+	for _, it := range m.matched {
+		if !m.isPinned(it.path) {
+			n++
+		}
+	}
+	return n
+}
+
+// statusLine returns the dimmed line shown above the footer, or "" when
+// there is nothing to report.
+func (m model) statusLine() string {
+	if m.local.err != nil {
+		return "\033[31m" + m.local.err.Error() + "\033[0m"
+	}
+	if m.notice != "" {
+		return m.notice
+	}
+	if m.readTimedOut {
+		return "\033[31mtimed out reading " + abbreviateHome(m.reading.path) + "\033[0m"
+	}
+	if m.readPending {
+		return fmt.Sprintf("\033[90m%s reading %s…\033[0m", spinnerFrames[m.spinFrame], abbreviateHome(m.reading.path))
+	}
+	if m.sizing {
+		return fmt.Sprintf("\033[90m%s measured %d of %d folders\033[0m", spinnerFrames[m.spinFrame], m.measured, m.sizer.total)
+	}
+	if m.busy() {
+		return fmt.Sprintf("\033[90m%s scanned %d dirs, %d matches (%.1fs)\033[0m",
+			spinnerFrames[m.spinFrame], m.walkProgress.scanned, m.matchCount(), m.walkProgress.elapsed.Seconds())
+	}
+	if m.walkProgress.loop != "" {
+		return "\033[31msymlink loop: " + m.walkProgress.loop + "\033[0m"
+	}
+	if m.manifest != "" {
+		return "\033[90mfolders listed in " + m.manifest + "\033[0m"
+	}
+	var notes []string
+	if m.inverted {
+		notes = append(notes, "showing folders not matching the filter")
+	}
+	if m.opts.only != "" {
+		notes = append(notes, "only "+m.opts.only)
+	}
+	if m.opts.age.active() {
+		notes = append(notes, m.opts.age.String())
+	}
+	if note := m.skipped.String(); m.opts.countSkipped && note != "" {
+		notes = append(notes, note)
+	}
+	if len(notes) > 0 {
+		return "\033[90m(" + strings.Join(notes, "; ") + ")\033[0m"
+	}
+	if m.walks() {
+		return fmt.Sprintf("\033[90mscanned %d dirs, %d matches in %.1fs\033[0m",
+			m.walkProgress.scanned, m.matchCount(), m.walkProgress.elapsed.Seconds())
+	}
+	return ""
+}
+
+// showTimes reports whether folders are annotated with their modification time.
+func (m model) showTimes() bool {
+	return m.sort == sortModified || m.opts.showTimes
+}
+
+// annotation returns the dimmed text shown after a folder name, if any.
+func (m model) annotation(it item) string {
+	var notes []string
+	if m.opts.du && !it.stub && it.name != ".." {
+		if size, ok := m.sizes[it.path]; ok {
+			notes = append(notes, size.String())
+		} else if m.sizing {
+			notes = append(notes, "…")
+		}
+	}
+	if it.mount {
+		notes = append(notes, "[mount]")
+	}
+	if m.showTimes() && !it.modTime.IsZero() && it.name != ".." {
+		notes = append(notes, relativeTime(it.modTime))
+	}
+	if it.perms != "" {
+		notes = append(notes, it.perms)
+	}
+	return strings.Join(notes, "  ")
+}
+
+// annotated reports whether list lines may carry an annotation, which
+// needs the full terminal width.
+func (m model) annotated() bool {
+	return m.showTimes() || m.opts.mounts || m.opts.du || m.opts.linkTargets || m.opts.long
+}
+
+// readLinks records where each symlinked folder points. The link is read
+// one level, as written, so relative targets stay relative.
+func readLinks(items []item) {
+	for i := range items {
+		if target, err := os.Readlink(items[i].path); err == nil {
+			items[i].link = target
+		}
+	}
+}
+
+// maxLinkTarget caps how much of a symlink target is shown.
+const maxLinkTarget = 40
+
+// linkSuffix returns what follows a symlinked folder's name with
+// --link-targets: an @ and, dimmed, the target, shortened like the filter
+// line when it's long. plain is the same text without styling.
+func linkSuffix(it item) (styled, plain string) {
+	if it.link == "" {
+		return "", ""
+	}
+	target := ellipsisTail(it.link, maxLinkTarget)
+	return "@\033[90m → " + target + "\033[0m", "@ → " + target
+}
+
+// markMounts flags the items that are mount points: folders on a different
+// device than root, such as network shares and external drives. It takes a
+// stat per folder, hence --mounts, and marks nothing where device IDs
+// aren't available.
+func markMounts(root string, items []item) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return
+	}
+	dev, ok := deviceID(info)
+	if !ok {
+		return
+	}
+	for i := range items {
+		if info, err := os.Stat(items[i].path); err == nil {
+			if d, ok := deviceID(info); ok && d != dev {
+				items[i].mount = true
+			}
+		}
+	}
+}
+
+// rightAlign pads after text so note ends at the terminal's right edge,
+// and returns the padding plus the dimmed note.
+func (m model) rightAlign(text, note string) string {
+	pad := m.width - utf8.RuneCountInString(text) - utf8.RuneCountInString(note) - 1
+	if pad < 2 {
+		pad = 2
+	}
+	return strings.Repeat(" ", pad) + "\033[90m" + note + "\033[0m"
+}
+
+// relativeTime formats t compactly, like "5m ago" or "3d ago".
+func relativeTime(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo ago", int(d.Hours()/24/30))
+	default:
+		return fmt.Sprintf("%dy ago", int(d.Hours()/24/365))
+	}
+}
+
+// overlayActive reports whether a full-screen dialog replaces the listing.
+func (m model) overlayActive() bool {
+	return m.showHelp || m.showErrors || m.showMarks || m.showSlots || m.confirmDelete || m.confirmArchive || m.createMode || m.renameMode
+}
+
+// itemLine renders one entry of the list.
+func (m model) itemLine(it item, selected bool) string {
+	line := "  " + it.name
+	if selected {
+		line = "\033[1;34m> " + it.name + "\033[0m"
+	}
+	// The current folder's entry is an action rather than a folder, so it
+	// stands apart in cyan
+	if it.stub {
+		line = "  \033[36m" + it.name + "\033[0m"
+		if selected {
+			line = "\033[1;36m> " + it.name + "\033[0m"
+		}
+	}
+	// A green + next to the cursor column marks folders picked with --multi
+	if m.marks.has(it.path) {
+		line = " \033[32m+\033[0m" + it.name
+		if selected {
+			line = "\033[1;34m>\033[32m+\033[1;34m" + it.name + "\033[0m"
+		}
+	}
+	link, plain := linkSuffix(it)
+	line += link
+	if note := m.annotation(it); note != "" {
+		line += m.rightAlign("  "+it.name+plain, note)
+	}
+	return line
+}
+
 func (m model) View() string {
+	if m.opts.noColor {
+		return stripStyles(m.view())
+	}
+	return m.view()
+}
+
+func (m model) view() string {
 	if m.showHelp {
 		return m.helpView()
 	}
 
+	if m.showErrors {
+		return m.errorsView()
+	}
+
+	if m.showMarks {
+		return m.marksView()
+	}
+
+	if m.showSlots {
+		return m.slotsView()
+	}
+
 	if m.confirmDelete {
 		return m.confirmDeleteView()
 	}
@@ -521,61 +2018,151 @@ func (m model) View() string {
 		return m.createFolderView()
 	}
 
-	var lines []string
+	if m.renameMode {
+		return m.renameFolderView()
+	}
+
+	var head, lines, tail []string
 
 	// Show path
-	home, _ := os.UserHomeDir()
-	path := m.root
-	if strings.HasPrefix(path, home) {
-		path = "~" + path[len(home):]
-	}
-	lines = append(lines, "\033[1;34m"+path+"\033[0m")
+	head = append(head, m.headerView())
 
 	// Show error if any
 	if m.deleteError != "" {
-		lines = append(lines, "\033[31m"+m.deleteError+"\033[0m")
+		head = append(head, "\033[31m"+m.deleteError+"\033[0m")
 	} else if m.createError != "" {
-		lines = append(lines, "\033[31m"+m.createError+"\033[0m")
+		head = append(head, "\033[31m"+m.createError+"\033[0m")
 	} else if m.archiveError != "" {
-		lines = append(lines, "\033[31m"+m.archiveError+"\033[0m")
+		head = append(head, "\033[31m"+m.archiveError+"\033[0m")
 	} else if m.filter != "" {
-		lines = append(lines, "\033[33mFilter: "+m.filter+"_\033[0m")
+		head = append(head, "\033[33mFilter: "+m.filterTail()+"_\033[0m"+m.countLabel())
 	} else {
-		lines = append(lines, "\033[90mType to filter...\033[0m")
+		head = append(head, "\033[90mType to filter...\033[0m")
 	}
-	lines = append(lines, "") // empty line
 
-	// Items (with scrolling)
+	// Items (with scrolling), in columns on wide terminals
 	filtered := m.filtered()
 	visible := m.visibleLines()
+	extra := m.extraLines()
+	cols := m.columns()
+	rows := m.gridRows()
+	width := cellWidth(filtered)
 	start := m.offset
 	end := start + visible
-	if end > len(filtered) {
-		end = len(filtered)
+	if end > rows {
+		end = rows
 	}
+	labels := m.jumpTargets()
 
-	for i := start; i < end; i++ {
-		it := filtered[i]
-		if i == m.cursor {
-			lines = append(lines, "\033[1;34m> "+it.name+"\033[0m")
-		} else {
-			lines = append(lines, "  "+it.name)
+	for r := start; r < end; r++ {
+		var line string
+		for c := 0; c < cols; c++ {
+			i := c*rows + r
+			if i >= len(filtered) {
+				break
+			}
+			if c > 0 {
+				// Pad out the previous column
+				line += strings.Repeat(" ", width-2-utf8.RuneCountInString(filtered[i-rows].name))
+			}
+			if label, ok := labels[i]; ok {
+				line += m.jumpLine(filtered[i], label, i == m.cursor)
+			} else {
+				line += m.itemLine(filtered[i], i == m.cursor)
+			}
 		}
+		lines = append(lines, line)
+	}
+
+	// Explain an empty list so it doesn't look broken
+	if extra.hint {
+		lines = append(lines, "\033[90m  "+m.emptyState()+"\033[0m")
+	}
+
+	// Show how many matches were cut off by --max-results
+	if extra.more {
+		lines = append(lines, fmt.Sprintf("\033[90m  …and %d more\033[0m", m.truncated()))
 	}
 
 	// Show scroll indicator if needed
-	if len(filtered) > visible {
-		lines = append(lines, fmt.Sprintf("\033[90m(%d-%d of %d)\033[0m", start+1, end, len(filtered)))
-	} else {
-		lines = append(lines, "") // keep spacing consistent
+	if extra.scroll {
+		if cols > 1 {
+			lines = append(lines, fmt.Sprintf("\033[90m(rows %d-%d of %d)\033[0m", start+1, end, rows))
+		} else {
+			lines = append(lines, fmt.Sprintf("\033[90m(%d-%d of %d)\033[0m", start+1, end, len(filtered)))
+		}
+	}
+
+	if extra.status {
+		tail = append(tail, m.statusLine())
+	}
+
+	footer := " " + m.keys.footerHints() + " "
+	if m.sort != sortName || m.reverse {
+		footer += "• sort: " + m.sort.String()
+		if m.reverse {
+			footer += ", reversed"
+		}
+		footer += " "
 	}
+	footer += m.marksSummary()
+	tail = append(tail, "\033[48;5;236m\033[97m"+footer+"\033[0m")
 
-	lines = append(lines, "\033[48;5;236m\033[97m ↑↓ nav • Enter open • Tab select • ^N new • F1 help \033[0m")
+	if !m.opts.bottom {
+		lines = slices.Concat(head, []string{""}, lines, tail)
+		return strings.Join(lines, "\n")
+	}
 
+	// Bottom layout: the list grows upward from the filter line, like fzf,
+	// and blank lines push everything down to the bottom of the terminal
+	slices.Reverse(lines)
+	lines = slices.Concat(lines, []string{""}, head, tail)
+	if pad := m.height - len(lines); pad > 0 {
+		lines = append(make([]string, pad), lines...)
+	}
 	return strings.Join(lines, "\n")
 }
 
+// filterTail returns the filter as shown on the filter line. A filter too
+// long for the terminal scrolls like a text field: the end, where typing
+// happens, stays visible after a leading …, and the cursor stays on screen.
+func (m model) filterTail() string {
+	if m.width <= 0 {
+		return m.filter
+	}
+	return ellipsisTail(m.filter, m.width-len("Filter: ")-1-len(m.countText())) // 1 for the _ cursor
+}
+
+// ellipsisTail fits s into room runes by keeping its end after a leading …
+func ellipsisTail(s string, room int) string {
+	runes := []rune(s)
+	if len(runes) <= room {
+		return s
+	}
+	if room < 2 {
+		return ""
+	}
+	return "…" + string(runes[len(runes)-room+1:])
+}
+
+// headerRow returns the screen row of the path header.
+func (m model) headerRow() int {
+	if !m.opts.bottom {
+		return 0
+	}
+	// Below the header: the filter line, the optional status line and the footer
+	row := m.height - 3
+	if m.extraLines().status {
+		row--
+	}
+	return row
+}
+
 func installShellFunction() {
+	if runtime.GOOS == "windows" {
+		installPowerShellFunction()
+		return
+	}
 	home, _ := os.UserHomeDir()
 	shell := os.Getenv("SHELL")
 	rcFile := filepath.Join(home, ".zshrc")
@@ -637,38 +2224,438 @@ pf() {
 	fmt.Fprintln(os.Stderr, "  source "+rcName)
 }
 
+// installPowerShellFunction prints the PowerShell equivalent of the shell
+// function. pf doesn't edit $PROFILE itself, since its location and
+// encoding vary between PowerShell versions.
+func installPowerShellFunction() {
+	psFunc := `
+function pf {
+  $dir = & pf.exe @args
+  if ($dir -and (Test-Path -LiteralPath $dir -PathType Container)) {
+    Set-Location -LiteralPath $dir
+  }
+}
+`
+	fmt.Fprintln(os.Stderr, "Automatic installation isn't supported on Windows.")
+	fmt.Fprintln(os.Stderr, "Add this function to your PowerShell profile (notepad $PROFILE):")
+	fmt.Fprintln(os.Stderr, psFunc)
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "pf - folder picker")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Usage: pf [options] [start-path]")
+	fmt.Fprintln(os.Stderr, "       pf completion bash|zsh|fish")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --animate         Show the match count while filtering, briefly lit up as it changes")
+	fmt.Fprintln(os.Stderr, "  --timeout N       Quit without selecting after N seconds with no key pressed")
+	fmt.Fprintln(os.Stderr, "  --alt-screen      Use the full screen and restore the terminal on exit")
+	fmt.Fprintln(os.Stderr, "  --bottom          Anchor the picker to the bottom, with the list growing upward")
+	fmt.Fprintln(os.Stderr, "  --no-color        Draw plain text without colors (also with NO_COLOR or TERM=dumb)")
+	fmt.Fprintln(os.Stderr, "  --header-style S  Shorten long paths in the middle (default) or on the left")
+	fmt.Fprintln(os.Stderr, "  --max-results N   Show at most N matches")
+	fmt.Fprintln(os.Stderr, "  --mouse           Click path segments in the header to jump there")
+	fmt.Fprintln(os.Stderr, "  --height N        Use at most N rows of the terminal (default: all, or $PF_HEIGHT)")
+	fmt.Fprintln(os.Stderr, "  --scroll-margin N Keep N rows visible above and below the cursor (default 2, or $PF_SCROLL_MARGIN)")
+	fmt.Fprintln(os.Stderr, "  --query TEXT      Start with TEXT in the filter")
+	fmt.Fprintln(os.Stderr, "  --rank MODE       Order matches by position (prefix first, default) or score")
+	fmt.Fprintln(os.Stderr, "  --smart-case      Match case-sensitively when the filter has an uppercase letter")
+	fmt.Fprintln(os.Stderr, "  --match ALGO      How a filter word matches a name:")
+	fmt.Fprintln(os.Stderr, "                      fuzzy        letters in order with gaps, close fits first (default)")
+	fmt.Fprintln(os.Stderr, "                      subsequence  letters in order with gaps, no fit scoring; predictable")
+	fmt.Fprintln(os.Stderr, "                      substring    the word must appear whole; fewest surprises")
+	fmt.Fprintln(os.Stderr, "  --sort MODE       Sort folders by name (default), natural, modified, none or size")
+	fmt.Fprintln(os.Stderr, "  --no-sort         Keep folders in filesystem order (same as --sort none)")
+	fmt.Fprintln(os.Stderr, "  --leaves          List only leaf folders (no subfolders) below the start path")
+	fmt.Fprintln(os.Stderr, "  --repos           List only git repositories below the folder, recursively")
+	fmt.Fprintln(os.Stderr, "  --max-depth N     How many levels below the folder --repos looks (default 4, 0 = no limit)")
+	fmt.Fprintln(os.Stderr, "  --depth N         List every folder down to N levels below the folder, by path")
+	fmt.Fprintln(os.Stderr, "  --symlink-loop MODE  How --leaves treats symlinked folders:")
+	fmt.Fprintln(os.Stderr, "                    follow-once (default), skip or error (report loops)")
+	fmt.Fprintln(os.Stderr, "  --manifest        List the folders named in a .pf-dirs file, when there is one")
+	fmt.Fprintln(os.Stderr, "  --enter-selects   Enter selects the folder and exits; → opens it instead")
+	fmt.Fprintln(os.Stderr, "  --auto-descend    Open through folders that only hold a single subfolder")
+	fmt.Fprintln(os.Stderr, "  --cursor-start AT Start on the stub entry (default) or the first folder")
+	fmt.Fprintln(os.Stderr, "  --no-stub         List only subfolders; select the current folder with Ctrl+Space")
+	fmt.Fprintln(os.Stderr, "  --keep-stub       Keep the [. select this folder] entry listed while filtering")
+	fmt.Fprintln(os.Stderr, "  --show-parent     Add a .. entry to go to the parent folder")
+	fmt.Fprintln(os.Stderr, "  --wrap-siblings   Alt+←/→ go round from the last sibling folder to the first")
+	fmt.Fprintln(os.Stderr, "  --sticky-filter   Keep the filter when opening or leaving folders")
+	fmt.Fprintln(os.Stderr, "  --path-filter     Typing src/ opens src and filters its subfolders")
+	fmt.Fprintln(os.Stderr, "  --save-history    Remember filters across sessions for Ctrl+P recall")
+	fmt.Fprintln(os.Stderr, "  --tui TARGET      Draw the interface on TARGET (default stderr)")
+	fmt.Fprintln(os.Stderr, "  --output TARGET   Write the selected path to TARGET (default stdout)")
+	fmt.Fprintln(os.Stderr, "                    TARGET: stdout, stderr, tty, fd:N or a file path")
+	fmt.Fprintln(os.Stderr, "  --trailing-slash  Print the selected path with a trailing /")
+	fmt.Fprintln(os.Stderr, "  --no-trailing-slash  Print the selected path without a trailing /")
+	fmt.Fprintln(os.Stderr, "  --echo            Print \"→ path\" to stderr after selecting")
+	fmt.Fprintln(os.Stderr, "  --multi           Mark folders with Ctrl+X; Tab prints every marked path")
+	fmt.Fprintln(os.Stderr, "  --loop            Keep running after Tab, printing each selection; Ctrl+C ends")
+	fmt.Fprintln(os.Stderr, "  --preselect FILE  Start --multi with the folders listed in FILE marked")
+	fmt.Fprintln(os.Stderr, "  --socket PATH     Send the selected path to a Unix socket instead of printing it")
+	fmt.Fprintln(os.Stderr, "  --log-jumps FILE  Add each selection to FILE with the time, as a record of where you went")
+	fmt.Fprintln(os.Stderr, "  --append-to FILE  Also add the selected path as a line at the end of FILE")
+	fmt.Fprintln(os.Stderr, "  --env-path        Print paths in your home folder as $HOME/...")
+	fmt.Fprintln(os.Stderr, "  --tilde-path      Print paths in your home folder as ~/...")
+	fmt.Fprintln(os.Stderr, "  --name-only       Print only the selected folder's name, not its path")
+	fmt.Fprintln(os.Stderr, "  --root DIR        Don't navigate above DIR")
+	fmt.Fprintln(os.Stderr, "  --read-timeout S  Give up reading a folder after S seconds, e.g. on a slow mount")
+	fmt.Fprintln(os.Stderr, "  --du              Show each folder's total size (measured in the background)")
+	fmt.Fprintln(os.Stderr, "  --stats           Show the number of folders, and their total size with --du, in the header")
+	fmt.Fprintln(os.Stderr, "  --mounts          Mark folders that are mount points, such as network shares")
+	fmt.Fprintln(os.Stderr, "  --long            Show each folder's permissions and owner, e.g. drwxr-xr-x 0755 alice staff")
+	fmt.Fprintln(os.Stderr, "  --link-targets    Show where symlinked folders point, as name@ → target")
+	fmt.Fprintln(os.Stderr, "  --times           Show when each folder was last modified")
+	fmt.Fprintln(os.Stderr, "  --only PATTERN    List only folders whose name matches PATTERN, e.g. '202*'")
+	fmt.Fprintln(os.Stderr, "  --modified-within AGE  List only folders modified in the last AGE, e.g. 7d, 2w, 24h")
+	fmt.Fprintln(os.Stderr, "  --older-than AGE  List only folders not modified in the last AGE")
+	fmt.Fprintln(os.Stderr, "  --hidden-only     List only hidden (dot) folders")
+	fmt.Fprintln(os.Stderr, "  --show-hidden PATTERNS  Always list these dot-folders, e.g. '.github,.config*'")
+	fmt.Fprintln(os.Stderr, "                          (adds to those in "+abbreviateHome(shownPath())+")")
+	fmt.Fprintln(os.Stderr, "  --count-skipped   Show how many hidden and ignored folders are left out")
+	fmt.Fprintln(os.Stderr, "  --no-ignore       Show node_modules, vendor and folders ignored by .pf files")
+	fmt.Fprintln(os.Stderr, "  --trash           Move deleted folders to the trash instead of removing them")
+	fmt.Fprintln(os.Stderr, "  --allow-local-config  Read settings from .pf files in the folders you visit")
+	fmt.Fprintln(os.Stderr, "  --debug           Print errors pf worked around to stderr on exit")
+	fmt.Fprintln(os.Stderr, "  --verbose, -V     Log loads, filter edits, navigation and errors to ~/.cache/pf/pf.log")
+	fmt.Fprintln(os.Stderr, "  --dry-run         Print what pf would select for --query, without the picker")
+	fmt.Fprintln(os.Stderr, "  --list            Print every folder matching --query, best first, without the picker")
+	fmt.Fprintln(os.Stderr, "  --select-current  Print the resolved start path without the picker")
+	fmt.Fprintln(os.Stderr, "  --save-query      Remember the filter a folder was selected with")
+	fmt.Fprintln(os.Stderr, "  --last-query      Print the filter saved by --save-query and exit")
+	fmt.Fprintln(os.Stderr, "  --print-config    Print the settings in effect, and where each came from, and exit")
+	fmt.Fprintln(os.Stderr, "  --install         Show shell function installation instructions")
+	fmt.Fprintln(os.Stderr, "  --help, -h        Show this help")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Shell completion:")
+	fmt.Fprintln(os.Stderr, "  bash  echo 'source <(pf completion bash)' >> ~/.bashrc")
+	fmt.Fprintln(os.Stderr, "  zsh   pf completion zsh > \"${fpath[1]}/_pf\"")
+	fmt.Fprintln(os.Stderr, "  fish  pf completion fish > ~/.config/fish/completions/pf.fish")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Without a start path, pf starts in $PF_DEFAULT_DIR if set, else the current directory.")
+	fmt.Fprintln(os.Stderr, "A start path like @work starts in the folder aliased as work in "+abbreviateHome(aliasesPath())+".")
+	fmt.Fprintln(os.Stderr, "$PF_HEIGHT, $PF_SCROLL_MARGIN, $PF_SOCKET and $PF_LOG_JUMPS set the defaults for --height, --scroll-margin, --socket and --log-jumps.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Press F1 inside pf for keyboard shortcuts.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "https://pf.pm7.dev")
+}
+
 func main() {
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
-		case "--help", "-h":
-			fmt.Fprintln(os.Stderr, "pf - folder picker")
-			fmt.Fprintln(os.Stderr, "")
-			fmt.Fprintln(os.Stderr, "Usage: pf [start-path]")
-			fmt.Fprintln(os.Stderr, "")
-			fmt.Fprintln(os.Stderr, "Options:")
-			fmt.Fprintln(os.Stderr, "  --install    Show shell function installation instructions")
-			fmt.Fprintln(os.Stderr, "  --help, -h   Show this help")
-			fmt.Fprintln(os.Stderr, "")
-			fmt.Fprintln(os.Stderr, "Press F1 inside pf for keyboard shortcuts.")
-			fmt.Fprintln(os.Stderr, "")
-			fmt.Fprintln(os.Stderr, "https://pf.pm7.dev")
-			return
-		case "--install":
-			installShellFunction()
-			return
+	// pf completion SHELL prints a completion script. Use ./completion
+	// to start in a folder of that name.
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, "Usage: pf completion {"+strings.Join(completionShells, ",")+"}")
+			os.Exit(2)
+		}
+		script, err := completionScript(os.Args[2])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+			os.Exit(2)
+		}
+		fmt.Print(script)
+		return
+	}
+
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+		fmt.Fprintln(os.Stderr, "Run 'pf --help' for usage.")
+		os.Exit(2)
+	}
+	if opts.help {
+		printUsage()
+		return
+	}
+	if opts.install {
+		installShellFunction()
+		return
+	}
+	if opts.verbose {
+		// The log can't go to stderr, where the interface is drawn
+		if err := openVerboseLog(); err != nil {
+			fmt.Fprintln(os.Stderr, "pf: --verbose: "+err.Error())
+		}
+		logf("start", "args=%q", os.Args[1:])
+	}
+	// PF_DEFAULT_DIR replaces the current directory as the default start
+	if dir := os.Getenv("PF_DEFAULT_DIR"); dir != "" && opts.start == "" {
+		if _, err := resolveStart(dir); err != nil {
+			fmt.Fprintln(os.Stderr, "pf: PF_DEFAULT_DIR: "+err.Error()+"; using the current directory")
+		} else {
+			opts.start = dir
+			opts.setSource("start", "env PF_DEFAULT_DIR")
+		}
+	}
+	// A start path like @work names a folder in the aliases file
+	if start, err := expandAlias(opts.start); err != nil {
+		fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+		os.Exit(2)
+	} else if start != opts.start {
+		opts.start = start
+		opts.setSource("start", aliasesPath())
+	}
+	// PF_SOCKET is the default for --socket
+	if v := os.Getenv("PF_SOCKET"); v != "" && opts.socket == "" {
+		opts.socket = v
+		opts.setSource("socket", "env PF_SOCKET")
+	}
+	// PF_LOG_JUMPS is the default for --log-jumps
+	if v := os.Getenv("PF_LOG_JUMPS"); v != "" && opts.logJumps == "" {
+		opts.logJumps = v
+		opts.setSource("log-jumps", "env PF_LOG_JUMPS")
+	}
+	// Dumb terminals and NO_COLOR get --no-color
+	if !opts.noColor && plainTerminal() {
+		opts.noColor = true
+		opts.setSource("no-color", "env NO_COLOR")
+		if os.Getenv("NO_COLOR") == "" {
+			opts.setSource("no-color", "env TERM")
+		}
+	}
+	// PF_SHOW_HIDDEN is the default for --show-hidden
+	if v := os.Getenv("PF_SHOW_HIDDEN"); v != "" && opts.showHidden == nil {
+		patterns, err := parsePatterns(v)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "pf: PF_SHOW_HIDDEN: "+err.Error())
+			os.Exit(2)
+		}
+		opts.showHidden = patterns
+		opts.setSource("show-hidden", "env PF_SHOW_HIDDEN")
+	}
+	// The show-hidden file adds to both
+	if err := addShown(&opts); err != nil {
+		fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+		os.Exit(2)
+	}
+	// PF_HEIGHT is the default for --height
+	if v := os.Getenv("PF_HEIGHT"); v != "" && opts.height == 0 {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 {
+			fmt.Fprintln(os.Stderr, "pf: PF_HEIGHT: invalid value "+v+"; using the full terminal")
+		} else {
+			opts.height = n
+			opts.setSource("height", "env PF_HEIGHT")
+		}
+	}
+	// PF_SCROLL_MARGIN is the default for --scroll-margin
+	if v := os.Getenv("PF_SCROLL_MARGIN"); v != "" && !opts.hasScrollMargin {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 {
+			fmt.Fprintln(os.Stderr, "pf: PF_SCROLL_MARGIN: invalid value "+v+"; using "+strconv.Itoa(opts.scrollMargin))
+		} else {
+			opts.scrollMargin = n
+			opts.setSource("scroll-margin", "env PF_SCROLL_MARGIN")
+		}
+	}
+	if opts.boundary != "" {
+		boundary, err := resolveStart(opts.boundary)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "pf: --root: "+err.Error())
+			os.Exit(2)
+		}
+		opts.boundary = boundary
+		if !isWithin(expandPath(opts.start), boundary) {
+			fmt.Fprintln(os.Stderr, "pf: start path is outside --root "+boundary)
+			os.Exit(2)
+		}
+	}
+	if opts.printConfig {
+		printConfig(os.Stdout, resolveConfig(opts))
+		return
+	}
+	if opts.lastQuery {
+		query, ok := loadLastQuery()
+		if !ok {
+			os.Exit(1)
+		}
+		fmt.Println(query)
+		return
+	}
+	if opts.selectCurrent {
+		dir, err := resolveStart(opts.start)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+			os.Exit(1)
 		}
+		fmt.Println(dir)
+		return
 	}
 
-	start := ""
-	if len(os.Args) > 1 {
-		start = os.Args[1]
+	keys, err := loadKeymap(keymapPath())
+	if err == nil && opts.enterSelects {
+		keys, err = keys.enterSelects()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+		os.Exit(2)
+	}
+	protected, err := loadProtected(protectedPath())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+		os.Exit(2)
+	}
+	m := newModel(opts)
+	m.keys = keys
+	m.protected = protected
+	var recorded []string // folders already in the --append-to file
+	if opts.preselect != "" {
+		marks, missing, err := loadPreselect(opts.preselect)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "pf: --preselect: "+err.Error())
+			os.Exit(2)
+		}
+		m.marks = marks
+		// Resuming with the same file for both, the preselected folders
+		// are already recorded
+		if expandPath(opts.preselect) == expandPath(opts.appendTo) {
+			recorded = marks.paths
+		}
+		if missing > 0 {
+			m.notice = preselectNotice(missing)
+		}
+	}
+	if opts.dryRun {
+		os.Exit(m.dryRun())
+	}
+	if opts.list {
+		os.Exit(m.listMatches())
 	}
 
 	// Output TUI to stderr so shell capture $() only gets the selected path
-	p := tea.NewProgram(newModel(start), tea.WithOutput(os.Stderr))
-	final, _ := p.Run()
+	tui, err := openOutput(opts.tuiOutput)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+		os.Exit(2)
+	}
+	out, err := openOutput(opts.output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+		os.Exit(2)
+	}
+	defer out.Close()
+	if opts.loop {
+		// Files aren't buffered, so each path reaches the reader at once
+		// The picker notes each path itself, as --echo would draw over it
+		loopOpts := opts
+		loopOpts.echo = false
+		m.emit = func(paths []string) error { return printResult(out, loopOpts, paths, nil) }
+	}
+
+	in, ok := terminalInput()
+	if !ok {
+		// Without a terminal the picker can't run, but a query that
+		// names exactly one folder can still be answered
+		if dir, found := m.onlyMatch(); found {
+			if err := printResult(out, opts, []string{dir}, nil); err != nil {
+				exitWith(err)
+			}
+			return
+		}
+		fmt.Fprintln(os.Stderr, "pf: no terminal available; pf needs an interactive terminal")
+		fmt.Fprintln(os.Stderr, "    (or a --query that matches exactly one folder)")
+		os.Exit(1)
+	}
+
+	programOpts := []tea.ProgramOption{tea.WithOutput(tui), tea.WithInput(in)}
+	if opts.mouse {
+		programOpts = append(programOpts, tea.WithMouseCellMotion())
+	}
+	if opts.altScreen {
+		// Run finishes tearing the alternate screen down before returning,
+		// so the selected path below lands in the normal buffer
+		programOpts = append(programOpts, tea.WithAltScreen())
+	}
+	p := tea.NewProgram(m, programOpts...)
+	final, err := p.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pf: "+err.Error())
+		os.Exit(1)
+	}
+
+	if opts.debug {
+		m.errLog.write(os.Stderr)
+	}
+	if m, ok := final.(model); ok {
+		logf("exit", "selected=%q", m.chosen())
+		if opts.saveQuery && len(m.chosen()) > 0 {
+			if err := saveLastQuery(m.filter); err != nil {
+				fmt.Fprintln(os.Stderr, "pf: --save-query: "+err.Error())
+			}
+		}
+		if err := printResult(out, opts, m.chosen(), recorded); err != nil {
+			exitWith(err)
+		}
+	}
+}
+
+// exitWith prints each line of err after "pf: " and exits 1.
+func exitWith(err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintln(os.Stderr, "pf: "+line)
+	}
+	os.Exit(1)
+}
+
+// printResult writes the selected paths to the --socket, or to out when
+// there is none or it can't be reached. Paths in recorded aren't appended
+// to the --append-to file again. Every path is still written when
+// --log-jumps, --socket or --append-to fails; those errors are returned
+// together.
+func printResult(out *os.File, opts options, paths, recorded []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	var errs []error
+	var lines []string
+	for _, path := range paths {
+		lines = append(lines, formatResult(path, opts))
+	}
+	// Record the jump before handing the path over, whatever happens next
+	if opts.logJumps != "" {
+		now := time.Now()
+		for _, path := range paths {
+			if err := logJump(expandPath(opts.logJumps), path, now); err != nil {
+				errs = append(errs, fmt.Errorf("--log-jumps: %w", err))
+				break
+			}
+		}
+	}
+	sent := false
+	if opts.socket != "" {
+		err := sendToSocket(opts.socket, lines)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("--socket: %w; printed the path instead", err))
+		}
+		sent = err == nil
+	}
+	for i, path := range paths {
+		if !sent {
+			if _, err := fmt.Fprintln(out, lines[i]); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+		}
+		// Leave a trail in the scrollback; stdout is reserved for the path
+		if opts.echo {
+			fmt.Fprintln(os.Stderr, "→ "+path)
+		}
+		if !slices.Contains(recorded, path) {
+			if err := appendTo(opts, path); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	if m, ok := final.(model); ok && m.selected != "" {
-		fmt.Println(m.selected)
+// appendTo records the selected path in the --append-to file, if set.
+func appendTo(opts options, path string) error {
+	if opts.appendTo == "" {
+		return nil
+	}
+	if err := appendSelection(expandPath(opts.appendTo), path); err != nil {
+		return fmt.Errorf("--append-to: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// mkdirs creates the folders below root, which may be nested paths.
+func mkdirs(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Join(root, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testModel starts pf on dir with the given flags, as main would, on an
+// 80x24 terminal.
+func testModel(t *testing.T, dir string, args ...string) model {
+	t.Helper()
+	opts, err := parseArgs(append(args, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newModel(opts)
+	if opts.enterSelects {
+		if m.keys, err = m.keys.enterSelects(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resize(m, 80, 24)
+}
+
+func resize(m model, width, height int) model {
+	next, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: height})
+	return next.(model)
+}
+
+// key returns the message for a Bubble Tea key string, such as "enter",
+// "alt+s" or "x".
+func key(k string) tea.KeyMsg {
+	alt := false
+	if rest, ok := strings.CutPrefix(k, "alt+"); ok && k != "alt+" {
+		alt, k = true, rest
+	}
+	for t := tea.KeyType(-200); t < 200; t++ {
+		if t != tea.KeyRunes && (tea.KeyMsg{Type: t}).String() == k {
+			return tea.KeyMsg{Type: t, Alt: alt}
+		}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k), Alt: alt}
+}
+
+// press sends keys to m in order, typing the characters of any key that
+// isn't a named one.
+func press(m model, keys ...string) model {
+	for _, k := range keys {
+		next, _ := m.Update(key(k))
+		m = next.(model)
+	}
+	return m
+}
+
+// typeText types s into the filter one character at a time.
+func typeText(m model, s string) model {
+	for _, r := range s {
+		m = press(m, string(r))
+	}
+	return m
+}
+
+// names returns the names of the listed entries, without pinned ones.
+func names(m model) []string {
+	var list []string
+	for _, it := range m.filtered() {
+		if !m.isPinned(it.path) {
+			list = append(list, it.name)
+		}
+	}
+	return list
+}
+
+func cursorName(m model) string {
+	filtered := m.filtered()
+	if len(filtered) == 0 {
+		return ""
+	}
+	return filtered[m.cursor].name
+}
+
+func TestRenameKeepsCursorOnFolder(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha", "beta", "gamma")
+	m := testModel(t, dir, "--cursor-start", "first")
+	m = press(m, "ctrl+e")
+	if !m.renameMode || m.renameName != "alpha" {
+		t.Fatalf("rename mode %v with %q, want alpha", m.renameMode, m.renameName)
+	}
+	m = press(m, "backspace", "backspace", "backspace", "backspace", "backspace")
+	m = typeText(m, "zeta")
+	m = press(m, "enter")
+	if m.renameMode {
+		t.Fatalf("still renaming: %s", m.renameError)
+	}
+	if got := cursorName(m); got != "zeta" {
+		t.Errorf("cursor on %q after rename, want zeta", got)
+	}
+}
+
+func TestRenameRejectsExistingName(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha", "beta")
+	m := testModel(t, dir, "--cursor-start", "first")
+	m = press(m, "ctrl+e", "backspace", "backspace", "backspace", "backspace", "backspace")
+	m = typeText(m, "beta")
+	m = press(m, "enter")
+	if !m.renameMode || !strings.Contains(m.renameError, "already exists") {
+		t.Errorf("rename onto beta: mode %v, error %q", m.renameMode, m.renameError)
+	}
+}
+
+func TestNameTakenIgnoresTheFolderItself(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha", "beta")
+	alpha := filepath.Join(dir, "alpha")
+	if nameTaken(alpha, alpha) {
+		t.Error("a folder collides with itself")
+	}
+	if !nameTaken(filepath.Join(dir, "beta"), alpha) {
+		t.Error("beta doesn't count as taken")
+	}
+	if nameTaken(filepath.Join(dir, "new"), alpha) {
+		t.Error("a free name counts as taken")
+	}
+}
+
+func TestRenameCursorAfterPendingRead(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha", "beta", "gamma")
+	m := testModel(t, dir)
+	// Stand in for a --read-timeout read that hasn't finished yet
+	m.reading = startDirRead(m.reading.id+1, dir, m.listFilter(), m.errLog, m.opts, seconds(5))
+	m.readPending = true
+	m.placeCursor(filepath.Join(dir, "gamma"))
+	l, _ := m.reading.poll(seconds(5))
+	next, _ := m.Update(dirReadMsg{id: m.reading.id, listing: l})
+	m = next.(model)
+	if got := cursorName(m); got != "gamma" {
+		t.Errorf("cursor on %q once the read finished, want gamma", got)
+	}
+}
+
+func TestViewFitsShortTerminals(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 30 {
+		mkdirs(t, dir, fmt.Sprintf("dir%02d", i))
+	}
+	for _, layout := range [][]string{nil, {"--bottom"}} {
+		for _, filter := range []string{"", "nomatch"} {
+			for height := 5; height <= 14; height++ {
+				m := testModel(t, dir, layout...)
+				m = typeText(m, filter)
+				m = resize(m, 80, height)
+				m.notice = "notice"
+				got := len(strings.Split(m.View(), "\n"))
+				if got > height {
+					t.Errorf("%v filter %q: %d lines at height %d", layout, filter, got, height)
+				}
+				if filter == "" && m.visibleLines() < 1 {
+					t.Errorf("%v: no room for folders at height %d", layout, height)
+				}
+			}
+		}
+	}
+}
+
+func TestViewKeepsStatusWhenItFits(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 30 {
+		mkdirs(t, dir, fmt.Sprintf("dir%02d", i))
+	}
+	m := resize(testModel(t, dir), 80, 12)
+	m.notice = "notice"
+	view := m.View()
+	if !strings.Contains(view, "notice") || !strings.Contains(view, " of ") {
+		t.Errorf("status or scroll indicator missing at height 12:\n%s", view)
+	}
+}
+
+func TestAbbreviateHome(t *testing.T) {
+	t.Setenv("HOME", "/home/alex")
+	for path, want := range map[string]string{
+		"/home/alex":       "~",
+		"/home/alex/src":   "~/src",
+		"/home/alex2/src":  "/home/alex2/src",
+		"/home/al":         "/home/al",
+		"/home/alexandria": "/home/alexandria",
+		"/srv/home/alex":   "/srv/home/alex",
+	} {
+		if got := abbreviateHome(path); got != want {
+			t.Errorf("abbreviateHome(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if got := replaceHome("/home/alex/src", "$HOME"); got != "$HOME/src" {
+		t.Errorf("replaceHome = %q, want $HOME/src", got)
+	}
+}
+
+func TestWalkBatchesAreSorted(t *testing.T) {
+	dir := t.TempDir()
+	m := testModel(t, dir, "--depth", "1", "--sort", "natural")
+	batch := func(done bool, list ...string) {
+		var items []item
+		for _, name := range list {
+			items = append(items, item{name: name, path: filepath.Join(dir, name)})
+		}
+		next, _ := m.Update(walkMsg{id: m.walker.id, items: items, done: done})
+		m = next.(model)
+	}
+	batch(false, "d10", "d9")
+	m = press(m, "down", "down")
+	if got := cursorName(m); got != "d10" {
+		t.Fatalf("cursor on %q, want d10", got)
+	}
+	batch(true, "d1", "d2")
+	if got := strings.Join(names(m), " "); got != "d1 d2 d9 d10" {
+		t.Errorf("listed %s, want d1 d2 d9 d10", got)
+	}
+	if got := cursorName(m); got != "d10" {
+		t.Errorf("cursor moved to %q, want d10", got)
+	}
+}
+
+func TestAutoDescendNotBackUp(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "a/b/c/d", "a/b/e")
+	m := testModel(t, filepath.Join(dir, "a", "b"), "--auto-descend", "--show-parent")
+	// The .. entry opens a, which holds only b: stay there
+	m = press(m, "down")
+	if got := cursorName(m); got != ".." {
+		t.Fatalf("cursor on %q, want ..", got)
+	}
+	m = press(m, "enter")
+	if m.root != filepath.Join(dir, "a") {
+		t.Errorf("opening .. went to %s, want a", m.root)
+	}
+	if got := cursorName(m); got != "b" {
+		t.Errorf("cursor on %q, want b", got)
+	}
+	// Going down still follows single subfolders
+	m = press(m, "enter")
+	m = typeText(m, "c")
+	m = press(m, "enter")
+	if m.root != filepath.Join(dir, "a", "b", "c", "d") {
+		t.Errorf("opening c went to %s, want c/d", m.root)
+	}
+}
+
+func TestLongFilterFitsFilterLine(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha")
+	long := strings.Repeat("abcdefghij", 10) + "xyz"
+	for _, args := range [][]string{nil, {"--animate"}} {
+		m := resize(testModel(t, dir, args...), 30, 12)
+		m = typeText(m, long)
+		if m.filter != long {
+			t.Fatalf("filter cut to %q", m.filter)
+		}
+		var line string
+		for _, l := range strings.Split(stripStyles(m.View()), "\n") {
+			if strings.HasPrefix(l, "Filter: ") {
+				line = l
+			}
+		}
+		if n := utf8.RuneCountInString(line); n > 30 {
+			t.Errorf("%v: filter line %q is %d wide on a 30-column terminal", args, line, n)
+		}
+		before, _, _ := strings.Cut(line, "  ")
+		if !strings.HasPrefix(line, "Filter: …") || !strings.HasSuffix(before, "hijxyz_") {
+			t.Errorf("%v: filter line %q doesn't end with the typed text and cursor", args, line)
+		}
+	}
+}
+
+func TestEllipsisTail(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		room int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"a longer filter", 8, "… filter"},
+		{"café crème", 5, "…rème"},
+		{"abc", 1, ""},
+	} {
+		if got := ellipsisTail(tt.s, tt.room); got != tt.want {
+			t.Errorf("ellipsisTail(%q, %d) = %q, want %q", tt.s, tt.room, got, tt.want)
+		}
+	}
+}
+
+func TestParentAtFilesystemRoot(t *testing.T) {
+	root := filepath.VolumeName(os.TempDir()) + string(filepath.Separator)
+	m := testModel(t, root, "--show-parent")
+	if slices.ContainsFunc(m.filtered(), func(it item) bool { return it.name == ".." }) {
+		t.Error("a .. entry is listed at the root")
+	}
+	for _, k := range []string{"esc", "alt+left", "alt+right"} {
+		m = press(m, k)
+		if m.root != root {
+			t.Errorf("%s at the root went to %s", k, m.root)
+		}
+		if !strings.Contains(m.statusLine(), "already at the filesystem root") {
+			t.Errorf("%s at the root: status %q", k, m.statusLine())
+		}
+	}
+	if !strings.Contains(stripStyles(m.View()), "already at the filesystem root") {
+		t.Error("the notice isn't shown")
+	}
+}
+
+func TestParentAtRootBoundary(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "sub")
+	m := testModel(t, filepath.Join(dir, "sub"), "--root", dir)
+	m = press(m, "esc")
+	if m.root != dir {
+		t.Fatalf("esc went to %s, want %s", m.root, dir)
+	}
+	m = press(m, "esc")
+	if m.root != dir || !strings.Contains(m.statusLine(), "already at the top of") {
+		t.Errorf("esc at the boundary: in %s, status %q", m.root, m.statusLine())
+	}
+}
+
+func TestPlainFramesHaveNoEscapes(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha", "beta", "gamma")
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "dumb")
+	if !plainTerminal() {
+		t.Fatal("TERM=dumb doesn't count as a plain terminal")
+	}
+	m := testModel(t, dir, "--no-color", "--cursor-start", "first", "--multi")
+	frames := map[string]model{
+		"list":     m,
+		"filtered": typeText(m, "a"),
+		"marked":   press(m, "ctrl+x"),
+		"jumping":  press(m, "ctrl+g"),
+		"help":     press(m, "f1"),
+	}
+	for name, m := range frames {
+		view := m.View()
+		if strings.Contains(view, "\033") {
+			t.Errorf("%s: escape sequence in %q", name, view)
+		}
+		if name == "list" && !strings.Contains(view, "> alpha") {
+			t.Errorf("list: no plain cursor marker on alpha:\n%s", view)
+		}
+	}
+}
+
+func TestUnsavableHistoryIsReported(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha")
+	// A file where the state directory should be can't hold pf's files
+	blocked := filepath.Join(dir, "state")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_STATE_HOME", blocked)
+	m := testModel(t, dir, "--save-history")
+	m = typeText(m, "alp")
+	m = press(m, "alt+s", "3")
+	if m.slots[2] != "alp" {
+		t.Errorf("slot 3 holds %q, want alp", m.slots[2])
+	}
+	if !strings.Contains(m.notice, "couldn't be saved") {
+		t.Errorf("saving slot 3: notice %q", m.notice)
+	}
+	m = press(m, "enter")
+	if !strings.Contains(m.notice, "Couldn't save the filter history") {
+		t.Errorf("opening alpha: notice %q", m.notice)
+	}
+	if entries, _ := m.errLog.list(); len(entries) != 2 {
+		t.Errorf("%d errors logged, want 2", len(entries))
+	}
+}
+
+func TestShowHiddenFile(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, ".github", ".config", ".cache", "src")
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	mkdirs(t, config, "pf")
+	list := "# always list these\n.github\n\n.conf*\n"
+	if err := os.WriteFile(shownPath(), []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := parseArgs([]string{"--show-hidden", ".cache", "--cursor-start", "first", dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := addShown(&opts); err != nil {
+		t.Fatal(err)
+	}
+	m := newModel(opts)
+	if got := strings.Join(names(m), " "); got != ".cache .config .github src" {
+		t.Errorf("listed %s, want .cache .config .github src", got)
+	}
+	for _, line := range resolveConfig(opts) {
+		if line.key == "show-hidden" && (line.value != ".cache,.github,.conf*" || line.source != shownPath()) {
+			t.Errorf("--print-config: show-hidden=%s from %s", line.value, line.source)
+		}
+	}
+
+	if err := os.WriteFile(shownPath(), []byte(".git[\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadShown(shownPath()); err == nil || !strings.Contains(err.Error(), ":1: bad pattern") {
+		t.Errorf("a bad pattern gave %v", err)
+	}
+}
+
+func TestResizeKeepsCursorInPlace(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 100 {
+		mkdirs(t, dir, fmt.Sprintf("dir%02d", i))
+	}
+	// place is how far down the screen the cursor is, from 0 to 1
+	place := func(m model) float64 {
+		row := m.cursorRow() - m.offset
+		if row < 0 || row >= m.visibleLines() {
+			t.Fatalf("cursor row %d is off screen (offset %d, %d rows)", m.cursorRow(), m.offset, m.visibleLines())
+		}
+		return float64(row) / float64(m.visibleLines()-1)
+	}
+
+	// Narrow enough for a single column
+	m := resize(testModel(t, dir, "--cursor-start", "first"), 20, 50)
+	if m.columns() != 1 {
+		t.Fatalf("%d columns, want 1", m.columns())
+	}
+	// Scroll down, then bring the cursor back up to a quarter of the way,
+	// where keeping it on screen alone would leave it at the bottom
+	for range 80 {
+		m = press(m, "down")
+	}
+	for range m.visibleLines() * 3 / 4 {
+		m = press(m, "up")
+	}
+	before := place(m)
+	m = resize(m, 20, 14)
+	if after := place(m); after < before-0.15 || after > before+0.15 {
+		t.Errorf("cursor moved from %.2f to %.2f of the way down", before, after)
+	}
+
+	// Near the end, the list still fills the screen
+	m.cursor = len(m.filtered()) - 1
+	m.fixScroll()
+	m = resize(m, 20, 40)
+	place(m)
+	if last := m.offset + m.visibleLines(); last != m.gridRows() {
+		t.Errorf("rows %d to %d shown of %d after growing at the end", m.offset, last, m.gridRows())
+	}
+}
+
+func TestRenameKeepsNamesValidUTF8(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "café")
+	m := testModel(t, dir, "--cursor-start", "first")
+	m = press(m, "ctrl+e", "backspace")
+	if m.renameName != "caf" {
+		t.Fatalf("backspace left %q, want caf", m.renameName)
+	}
+	m = press(m, "é", "s")
+	m = press(m, "enter")
+	if m.renameMode {
+		t.Fatalf("still renaming: %s", m.renameError)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cafés")); err != nil {
+		t.Errorf("café wasn't renamed to cafés: %v", err)
+	}
+}
+
+func TestPrintConfigSources(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PF_DEFAULT_DIR", "")
+	opts, err := parseArgs([]string{"--height", "10", dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As main does for a start path like @work and for TERM=dumb
+	opts.setSource("start", "aliases")
+	opts.noColor = true
+	opts.setSource("no-color", "env TERM")
+	want := map[string]string{"start": "aliases", "no-color": "env TERM", "height": "flag", "sort": "default"}
+	for _, line := range resolveConfig(opts) {
+		if source, ok := want[line.key]; ok && line.source != source {
+			t.Errorf("%s=%s from %s, want %s", line.key, line.value, line.source, source)
+		}
+	}
+}
+
+func TestSwitchesTakeNoValue(t *testing.T) {
+	for _, arg := range []string{"--trash=false", "--multi=true", "-h=1"} {
+		if _, err := parseArgs([]string{arg}); err == nil || !strings.Contains(err.Error(), "doesn't take a value") {
+			t.Errorf("%s gave %v", arg, err)
+		}
+	}
+	opts, err := parseArgs([]string{"--depth=2", "--sort=natural", "a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.depth != 2 || opts.start != "a=b" {
+		t.Errorf("--depth=2 a=b parsed as depth %d, start %q", opts.depth, opts.start)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestReader reads one kind of manifest file for --manifest. Other
+// formats, such as package.json workspaces, can be supported by adding a
+// reader to manifestReaders.
+type manifestReader struct {
+	name  string                     // file name looked for in the current folder
+	parse func(data []byte) []string // folder paths or glob patterns, relative to the file
+}
+
+// manifestReaders are tried in order; the first file found is used.
+var manifestReaders = []manifestReader{
+	{name: ".pf-dirs", parse: parseDirList},
+}
+
+// parseDirList reads a .pf-dirs file: one folder per line, which may be a
+// glob pattern such as packages/*. Blank lines and # comments are skipped.
+func parseDirList(data []byte) []string {
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns
+}
+
+// loadManifest lists the folders named by the first manifest found in
+// dir, in the manifest's order, named by their path relative to dir.
+// Entries that don't exist or aren't folders are left out. file is the
+// manifest's name, and "" when dir has none or it couldn't be read.
+func loadManifest(dir string, log *errorLog) (items []item, file string) {
+	for _, r := range manifestReaders {
+		path := filepath.Join(dir, r.name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.add(path, err)
+			}
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, pattern := range r.parse(data) {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(dir, pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				log.add(path, err)
+				continue
+			}
+			for _, m := range matches {
+				info, err := os.Stat(m)
+				if err != nil || !info.IsDir() || seen[m] {
+					continue
+				}
+				seen[m] = true
+				name, err := filepath.Rel(dir, m)
+				if err != nil {
+					name = m
+				}
+				items = append(items, item{name: name, path: m, modTime: info.ModTime(), order: len(items)})
+			}
+		}
+		return items, r.name
+	}
+	return nil, ""
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// markSet holds the folders marked with --multi, by absolute path. Marks
+// outlive filtering and navigation, so folders marked in one place stay
+// selected after moving on.
+type markSet struct {
+	paths []string // in the order they were marked
+}
+
+func (s markSet) has(path string) bool {
+	return slices.Contains(s.paths, path)
+}
+
+// toggle marks path, or unmarks it if it was marked.
+func (s *markSet) toggle(path string) {
+	if s.has(path) {
+		s.remove(path)
+		return
+	}
+	s.paths = append(s.paths, path)
+}
+
+func (s *markSet) remove(path string) {
+	s.paths = slices.DeleteFunc(s.paths, func(p string) bool { return p == path })
+}
+
+func (s *markSet) clear() {
+	s.paths = nil
+}
+
+// loadPreselect reads the folders --preselect marks at startup, one per
+// line, as pf --multi prints them: absolute, or starting with ~/ or
+// $HOME/. Blank lines are skipped, and missing counts the folders that
+// no longer exist, which are left out.
+func loadPreselect(path string) (s markSet, missing int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "$HOME"); ok {
+			line = "~" + rest
+		}
+		dir := expandPath(line)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			missing++
+			continue
+		}
+		if !s.has(dir) {
+			s.paths = append(s.paths, dir)
+		}
+	}
+	return s, missing, nil
+}
+
+// preselectNotice reports, for the status line, the preselected folders
+// that were dropped.
+func preselectNotice(missing int) string {
+	if missing == 1 {
+		return "\033[90m1 preselected folder no longer exists\033[0m"
+	}
+	return fmt.Sprintf("\033[90m%d preselected folders no longer exist\033[0m", missing)
+}
+
+// hiddenMarks counts the marked folders the current listing doesn't show,
+// because they're filtered out or in another folder.
+func (m model) hiddenMarks() int {
+	shown := make(map[string]bool)
+	for _, it := range m.filtered() {
+		shown[it.path] = true
+	}
+	n := 0
+	for _, p := range m.marks.paths {
+		if !shown[p] {
+			n++
+		}
+	}
+	return n
+}
+
+// marksSummary returns the footer note counting the marked folders, or ""
+// when there are none.
+func (m model) marksSummary() string {
+	n := len(m.marks.paths)
+	if n == 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("• %d marked", n)
+	if hidden := m.hiddenMarks(); hidden > 0 {
+		summary += fmt.Sprintf(" (%d not shown)", hidden)
+	}
+	return summary + " "
+}
+
+// scrollWindow returns the rows [start, end) of a list of total rows to
+// show in visible lines, keeping the cursor in view.
+func scrollWindow(cursor, total, visible int) (start, end int) {
+	if total <= visible {
+		return 0, total
+	}
+	start = max(0, min(cursor-visible/2, total-visible))
+	return start, start + visible
+}
+
+// updateMarks handles keys while the marked folders overlay is open.
+func (m model) updateMarks(k string) model {
+	switch {
+	case k == "esc" || m.keys.action(k) == actMarks:
+		m.showMarks = false
+	case m.keys.action(k) == actUp:
+		m.marksCursor = max(0, m.marksCursor-1)
+	case m.keys.action(k) == actDown:
+		m.marksCursor = min(len(m.marks.paths)-1, m.marksCursor+1)
+	case k == "delete" || k == "backspace" || m.keys.action(k) == actMark:
+		if len(m.marks.paths) > 0 {
+			m.marks.remove(m.marks.paths[m.marksCursor])
+			m.marksCursor = max(0, min(m.marksCursor, len(m.marks.paths)-1))
+		}
+	case k == "ctrl+u":
+		m.marks.clear()
+		m.marksCursor = 0
+	}
+	return m
+}
+
+func (m model) marksView() string {
+	var lines []string
+	lines = append(lines, "")
+	lines = append(lines, fmt.Sprintf("  \033[1;34mMarked folders (%d)\033[0m", len(m.marks.paths)))
+	lines = append(lines, "")
+	if len(m.marks.paths) == 0 {
+		lines = append(lines, "  \033[90mNothing marked; press "+m.keys.label(actMark)+" on a folder to mark it\033[0m")
+	}
+	visible := len(m.marks.paths)
+	if m.height > 0 {
+		visible = max(1, m.height-8)
+	}
+	start, end := scrollWindow(m.marksCursor, len(m.marks.paths), visible)
+	for i := start; i < end; i++ {
+		p := m.marks.paths[i]
+		lines = append(lines, m.itemLine(item{name: abbreviateHome(p), path: p}, i == m.marksCursor))
+	}
+	if end-start < len(m.marks.paths) {
+		lines = append(lines, fmt.Sprintf("\033[90m(%d-%d of %d)\033[0m", start+1, end, len(m.marks.paths)))
+	}
+	lines = append(lines, "")
+	lines = append(lines, "  \033[90mDel unmark • Ctrl+U clear all • Esc or "+m.keys.label(actMarks)+" close\033[0m")
+	lines = append(lines, "")
+	return strings.Join(lines, "\n")
+}
+
+// chosen returns the paths to print on exit: every marked folder when
+// several were picked with --multi, otherwise the one selected folder.
+func (m model) chosen() []string {
+	if len(m.picked) > 0 {
+		return m.picked
+	}
+	if m.selected != "" {
+		return []string{m.selected}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// nameSeparators separate the words of a folder name, and / the folders
+// of a path. The filter ignores them, so "my_project" finds my-project
+// and "srcapp" finds src/app, and they mark word starts.
+const nameSeparators = " -_./"
+
+// filterWords splits a filter into words with separators and accents
+// removed, lowercased unless exactCase is set. Separator-only words are
+// dropped.
+func filterWords(filter string, exactCase bool) []string {
+	filter = foldAccents(filter)
+	if !exactCase {
+		filter = strings.ToLower(filter)
+	}
+	var words []string
+	for _, w := range strings.Fields(filter) {
+		if w = stripSeparators(w); w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// foldAccents strips diacritics from s, so "résumé" becomes "resume" and
+// typing plain letters finds accented names. Letters are decomposed, the
+// combining marks dropped, and what's left recomposed.
+func foldAccents(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		return s
+	}
+	return folded
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// smartCase reports whether filter should match case-sensitively with
+// --smart-case: when it has an uppercase letter.
+func smartCase(filter string) bool {
+	return strings.IndexFunc(filter, unicode.IsUpper) >= 0
+}
+
+// stripSeparators removes the nameSeparators from s.
+func stripSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(nameSeparators, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Match classes for --rank position, best first.
+const (
+	matchPrefix    = iota // the name starts with the word
+	matchSubstring        // the word appears whole inside the name
+	matchScattered        // only a fuzzy match
+)
+
+// matchPosition classifies how word matches name and where the match
+// starts. Separators are ignored, as in the filter, so "myp" is a prefix
+// of "my-project". Unless exactCase is set, case is ignored and word
+// should already be lowercase.
+func matchPosition(name, word string, exactCase bool) (class, pos int) {
+	n := stripSeparators(name)
+	if !exactCase {
+		n = strings.ToLower(n)
+	}
+	switch i := strings.Index(n, word); {
+	case i == 0:
+		return matchPrefix, 0
+	case i > 0:
+		return matchSubstring, i
+	}
+	first, _ := utf8.DecodeRuneInString(word)
+	return matchScattered, strings.IndexRune(n, first)
+}
+
+// fuzzyScore matches word against name as a subsequence: every character
+// of word must appear in name, in order, but not necessarily adjacent.
+// Consecutive characters and characters at the start of a word (after a
+// separator or a camelCase hump) score higher, gaps score lower. Unless
+// exactCase is set, case is ignored and word should already be lowercase.
+func fuzzyScore(name, word string, exactCase bool) (int, bool) {
+	orig := []rune(name)
+	n := make([]rune, len(orig))
+	for i, r := range orig {
+		n[i] = r
+		if !exactCase {
+			n[i] = unicode.ToLower(r)
+		}
+	}
+	score := 0
+	prev := -1
+	pos := 0
+	for _, c := range word {
+		idx := -1
+		for i := pos; i < len(n); i++ {
+			if n[i] == c {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return 0, false
+		}
+		score++
+		switch {
+		case idx == prev+1 && prev >= 0:
+			score += 5 // consecutive
+		case prev >= 0:
+			score -= min(idx-prev-1, 3) // gap
+		}
+		if idx == 0 || strings.ContainsRune(nameSeparators, n[idx-1]) ||
+			unicode.IsUpper(orig[idx]) && unicode.IsLower(orig[idx-1]) {
+			score += 3 // start of a word
+		}
+		prev = idx
+		pos = idx + 1
+	}
+	return score, true
+}
+
+// Matching algorithms for --match.
+const (
+	matcherFuzzy       = "fuzzy"       // letters in order, scored by how closely they fit
+	matcherSubsequence = "subsequence" // letters in order, every fit scoring alike
+	matcherSubstring   = "substring"   // each word must appear whole
+)
+
+var matcherNames = []string{matcherFuzzy, matcherSubsequence, matcherSubstring}
+
+// matchWord matches one filter word against name with the given
+// algorithm. Only fuzzy matching scores; the others leave ranking to the
+// match position, or to the listing order with --rank score.
+func matchWord(name, word, matcher string, exactCase bool) (int, bool) {
+	switch matcher {
+	case matcherSubstring:
+		class, _ := matchPosition(name, word, exactCase)
+		return 0, class != matchScattered
+	case matcherSubsequence:
+		_, ok := fuzzyScore(name, word, exactCase)
+		return 0, ok
+	}
+	return fuzzyScore(name, word, exactCase)
+}
+
+// matchText returns the text the filter is matched against: the name as
+// displayed, which in --leaves mode is the path relative to the start
+// folder. Separators are normalized to / so "src/app" matches on every
+// platform, and a word can match across them ("srcapp" matches src/app).
+// The current folder's entry matches by the folder's name.
+func matchText(it item) string {
+	if it.stub {
+		return filepath.ToSlash(filepath.Base(it.path))
+	}
+	return filepath.ToSlash(it.name)
+}
+
+// scoreWords matches every word against name with matcher. All words
+// must match, in any order relative to each other, ignoring accents and,
+// unless exactCase is set, case. The scores are summed; the match class is
+// the worst of the words' and the positions are summed.
+func scoreWords(name string, words []string, matcher string, exactCase bool) (scoredItem, bool) {
+	var m scoredItem
+	name = foldAccents(name)
+	for _, w := range words {
+		s, ok := matchWord(name, w, matcher, exactCase)
+		if !ok {
+			return m, false
+		}
+		class, pos := matchPosition(name, w, exactCase)
+		m.score += s
+		m.class = max(m.class, class)
+		m.pos += pos
+	}
+	return m, true
+}
+
+// scoredItem is a match with its score, used to rank filter results.
+type scoredItem struct {
+	item
+	score int
+	class int // best is matchPrefix
+	pos   int // where the words matched, earlier is better
+}
+
+// Ranking modes for --rank.
+const (
+	rankPosition = "position" // prefix, then substring, then fuzzy matches
+	rankScore    = "score"    // fuzzy score only
+)
+
+var rankModeNames = []string{rankPosition, rankScore}
+
+// rankMatches orders matches best first. By position, prefix matches come
+// first, then substring matches, then scattered ones; within a class
+// earlier matches win, and then names sort alphabetically. By score, ties
+// keep their listing order.
+func rankMatches(matches []scoredItem, mode string) []item {
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if mode != rankPosition {
+			return a.score > b.score
+		}
+		if a.class != b.class {
+			return a.class < b.class
+		}
+		// Scattered matches are only comparable by how well they fit
+		if a.class == matchScattered && a.score != b.score {
+			return a.score > b.score
+		}
+		if a.pos != b.pos {
+			return a.pos < b.pos
+		}
+		return strings.ToLower(a.name) < strings.ToLower(b.name)
+	})
+	result := make([]item, len(matches))
+	for i, s := range matches {
+		result[i] = s.item
+	}
+	return result
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// matchNames returns the names in list that filter matches, best first,
+// as the picker would list them.
+func matchNames(filter string, list ...string) []string {
+	m := model{filter: filter, opts: options{match: matcherFuzzy, rank: rankPosition}}
+	for _, name := range list {
+		m.entries = append(m.entries, item{name: name, path: "/x/" + name})
+	}
+	var result []string
+	for _, it := range m.matches() {
+		result = append(result, it.name)
+	}
+	return result
+}
+
+func TestFuzzyMatchesEveryWord(t *testing.T) {
+	got := matchNames("pro web", "web-project", "project", "website", "my-project-web", "prowler")
+	want := []string{"my-project-web", "web-project"}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+}
+
+func TestFuzzyRanksCloserFitsFirst(t *testing.T) {
+	got := matchNames("doc", "dxoxc", "my-docs", "docs", "dxoc")
+	want := []string{"docs", "my-docs", "dxoc", "dxoxc"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ranked %v, want %v", got, want)
+	}
+}
+
+func TestBlankFilterMatchesEverything(t *testing.T) {
+	list := []string{"beta", "alpha", "gamma"}
+	for _, filter := range []string{"", " ", "   \t", " - _ "} {
+		if got := matchNames(filter, list...); !slices.Equal(got, list) {
+			t.Errorf("filter %q listed %v, want %v", filter, got, list)
+		}
+	}
+}
+
+func TestMatchesKeptCurrent(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "apple", "apricot", "banana", "blueberry", "cherry")
+	m := testModel(t, dir)
+	check := func(step string) {
+		t.Helper()
+		var got, want []string
+		for _, it := range m.matched {
+			got = append(got, it.name)
+		}
+		for _, it := range m.matches() {
+			want = append(want, it.name)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("after %s: stored %v, want %v", step, got, want)
+		}
+	}
+	check("start")
+	m = typeText(m, "ap")
+	check("typing")
+	if got := strings.Join(names(m), " "); got != "apple apricot" {
+		t.Errorf("ap listed %s", got)
+	}
+	m = press(m, "alt+i")
+	check("inverting")
+	m = press(m, "alt+i", "backspace")
+	check("backspace")
+	m = press(m, "ctrl+u")
+	check("clearing")
+	m = press(m, "ctrl+s", "ctrl+r")
+	check("sorting")
+	m = press(m, "f5")
+	check("refreshing")
+	if len(names(m)) != 5 {
+		t.Errorf("listed %v after clearing the filter", names(m))
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	paths := []string{"src/app", "lib/app", "src/lib", "docs"}
+	for _, matcher := range matcherNames {
+		for filter, want := range map[string][]string{
+			"src/app": {"src/app"},
+			"srcapp":  {"src/app"},
+			"src app": {"src/app"},
+			"app src": {"src/app"},
+			"app":     {"lib/app", "src/app"},
+			"src":     {"src/app", "src/lib"},
+			"/lib":    {"lib/app", "src/lib"},
+		} {
+			m := model{filter: filter, opts: options{match: matcher, rank: rankPosition}}
+			for _, p := range paths {
+				m.entries = append(m.entries, item{name: filepath.FromSlash(p), path: "/x/" + p})
+			}
+			var got []string
+			for _, it := range m.matches() {
+				got = append(got, filepath.ToSlash(it.name))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s %q matched %v, want %v", matcher, filter, got, want)
+			}
+		}
+	}
+}
+
+func TestPathFilterInLeavesMode(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "src/app", "src/lib", "web/app")
+	m := testModel(t, dir, "--leaves", "--match", "substring")
+	m.finishLoading()
+	m = typeText(m, "srcapp")
+	if got := names(m); !slices.Equal(got, []string{filepath.Join("src", "app")}) {
+		t.Errorf("srcapp listed %v, want src/app", got)
+	}
+}
+
+func TestSeparatorsAreInterchangeable(t *testing.T) {
+	names := []string{"my-project", "my_project", "myProject", "my project", "my.project", "other"}
+	want := []string{"my project", "my-project", "my.project", "myProject", "my_project"}
+	for _, matcher := range matcherNames {
+		for _, filter := range []string{"my project", "my-project", "my_project", "myproject", "myProject", "my.project"} {
+			m := model{filter: filter, opts: options{match: matcher, rank: rankPosition}}
+			for _, name := range names {
+				m.entries = append(m.entries, item{name: name, path: "/x/" + name})
+			}
+			var got []string
+			for _, it := range m.matches() {
+				got = append(got, it.name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s %q matched %v, want %v", matcher, filter, got, want)
+			}
+		}
+	}
+}
+
+func TestCamelCaseHumpsScoreAsWordStarts(t *testing.T) {
+	// "mp" starts both words of myProject but not of mapper
+	got := matchNames("mp", "mapper", "myProject")
+	if len(got) != 2 || got[0] != "myProject" {
+		t.Errorf("ranked %v, want myProject first", got)
+	}
+}
+
+func TestPrefixMatchesSortFirst(t *testing.T) {
+	list := []string{"my-docs", "xdxoxcx", "docker", "Docs", "adoc", "documents"}
+	got := matchNames("doc", list...)
+	// Prefixes alphabetically, then substrings by position, then the rest
+	want := []string{"docker", "Docs", "documents", "adoc", "my-docs", "xdxoxcx"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ranked %v, want %v", got, want)
+	}
+}
+
+func TestRankScoreKeepsListingOrderForTies(t *testing.T) {
+	m := model{filter: "doc", opts: options{match: matcherSubstring, rank: rankScore}}
+	for _, name := range []string{"my-docs", "docs", "adoc"} {
+		m.entries = append(m.entries, item{name: name, path: "/x/" + name})
+	}
+	var got []string
+	for _, it := range m.matches() {
+		got = append(got, it.name)
+	}
+	if want := []string{"my-docs", "docs", "adoc"}; !slices.Equal(got, want) {
+		t.Errorf("ranked %v, want %v", got, want)
+	}
+}
+
+func TestBestMatchAfterTyping(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "app-docs", "docs", "zdoc")
+	m := typeText(testModel(t, dir), "doc")
+	if got := cursorName(m); got != "docs" {
+		t.Errorf("cursor on %q, want the prefix match docs", got)
+	}
+}
+
+func TestAccentsFold(t *testing.T) {
+	list := []string{"café", "résumé", "Ærø", "naïve-ideas", "cafeteria", "resume-old"}
+	for filter, want := range map[string][]string{
+		"cafe":   {"cafeteria", "café"},
+		"café":   {"cafeteria", "café"},
+		"resume": {"resume-old", "résumé"},
+		"naive":  {"naïve-ideas"},
+		"ærø":    {"Ærø"},
+	} {
+		got := matchNames(filter, list...)
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("%q matched %v, want %v", filter, got, want)
+		}
+	}
+	// Decomposed names, as macOS stores them, match too
+	if got := matchNames("cafe", "café"); len(got) != 1 {
+		t.Errorf("decomposed café not matched")
+	}
+}
+
+func TestAccentedFolders(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "café", "résumé", "plain")
+	m := typeText(testModel(t, dir), "resume")
+	if got := names(m); !slices.Equal(got, []string{"résumé"}) {
+		t.Errorf("resume listed %v, want résumé", got)
+	}
+	if view := m.View(); !strings.Contains(view, "résumé") {
+		t.Error("the accented name isn't shown as it is")
+	}
+}
+
+func TestSmartCase(t *testing.T) {
+	list := []string{"Docs", "docs", "my-Docs", "DOCS"}
+	for _, tt := range []struct {
+		filter string
+		smart  bool
+		want   []string
+	}{
+		{"docs", true, []string{"DOCS", "Docs", "docs", "my-Docs"}},
+		{"Docs", true, []string{"Docs", "my-Docs"}},
+		{"DOCS", true, []string{"DOCS"}},
+		{"dOcs", true, nil},
+		{"Docs", false, []string{"DOCS", "Docs", "docs", "my-Docs"}},
+		{"Dcs", true, []string{"Docs", "my-Docs"}},
+		{"Resume", true, []string{"Résumé"}},
+		{"resume", true, []string{"Résumé", "résumé"}},
+	} {
+		m := model{filter: tt.filter, opts: options{match: matcherFuzzy, rank: rankPosition, smartCase: tt.smart}}
+		for _, name := range append(list, "Résumé", "résumé") {
+			m.entries = append(m.entries, item{name: name, path: "/x/" + name})
+		}
+		var got []string
+		for _, it := range m.matches() {
+			got = append(got, it.name)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q (smart case %v) matched %v, want %v", tt.filter, tt.smart, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// deviceID is unavailable here, so --mounts marks nothing.
+func deviceID(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// crossDevice can't tell here why a rename failed.
+func crossDevice(err error) bool {
+	return false
+}
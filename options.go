@@ -0,0 +1,469 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// options holds the settings given on the command line.
+type options struct {
+	start           string   // start path (positional argument)
+	help            bool     // --help: print usage and exit
+	install         bool     // --install: install the shell function and exit
+	maxResults      int      // --max-results: cap on shown matches, 0 = unlimited
+	mouse           bool     // --mouse: enable mouse clicks on the path header
+	sort            sortMode // --sort: initial folder order
+	leaves          bool     // --leaves: list leaf folders found recursively
+	repos           bool     // --repos: list git repositories found recursively
+	maxDepth        int      // --max-depth: levels below the folder --repos looks, 0 = no limit
+	depth           int      // --depth: list every folder down to N levels, recursively, 0 = off
+	showParent      bool     // --show-parent: add a .. entry after [current]
+	tuiOutput       string   // --tui: where the interface is drawn (default stderr)
+	output          string   // --output: where the selected path goes (default stdout)
+	selectCurrent   bool     // --select-current: print the resolved start path and exit
+	stickyFilter    bool     // --sticky-filter: keep the filter across navigation
+	trailingSlash   bool     // --trailing-slash: print the path with a trailing /
+	noTrailingSlash bool     // --no-trailing-slash: strip a trailing / from the path
+	boundary        string   // --root: folder pf may not navigate above
+	showTimes       bool     // --times: show how long ago each folder changed
+	hiddenOnly      bool     // --hidden-only: list only dot-folders
+	trash           bool     // --trash: delete moves folders to the trash
+	localConfig     bool     // --allow-local-config: read .pf files while navigating
+	nameOnly        bool     // --name-only: print the folder's base name instead of its path
+	noIgnore        bool     // --no-ignore: list folders that are normally skipped
+	saveHistory     bool     // --save-history: keep the filter history on disk
+	symlinkLoop     linkMode // --symlink-loop: how walks treat symlinked folders
+	echo            bool     // --echo: confirm the selection on stderr
+	autoDescend     bool     // --auto-descend: open through single-subfolder chains
+	enterSelects    bool     // --enter-selects: Enter selects and → opens
+	scrollMargin    int      // --scroll-margin: rows kept visible around the cursor
+	hasScrollMargin bool     // --scroll-margin was given, so PF_SCROLL_MARGIN doesn't apply
+	debug           bool     // --debug: print the error log to stderr on exit
+	verbose         bool     // --verbose: log events to pf.log in the cache directory
+	printConfig     bool     // --print-config: print the settings in effect and exit
+	query           string   // --query: initial filter text
+	bottom          bool     // --bottom: anchor the picker to the bottom of the terminal
+	altScreen       bool     // --alt-screen: draw on the alternate screen buffer
+	animate         bool     // --animate: animate the match count as the filter changes
+	dryRun          bool     // --dry-run: print the best match for --query and exit
+	list            bool     // --list: print every match for --query, best first, and exit
+	noColor         bool     // --no-color: draw without colors or other styling
+	headerStyle     string   // --header-style: where long paths are shortened, "middle" or "left"
+	pathFilter      bool     // --path-filter: a typed / opens the folder named so far
+	height          int      // --height: most terminal rows the picker uses, 0 = all
+	appendTo        string   // --append-to: file the selected path is also appended to
+	logJumps        string   // --log-jumps: file each selection is recorded in with its time
+	multi           bool     // --multi: mark several folders and print them all
+	loop            bool     // --loop: print each selection and keep running until quit
+	preselect       string   // --preselect: file of folders marked at startup, implies --multi
+	rank            string   // --rank: how matches are ordered, "position" or "score"
+	match           string   // --match: matching algorithm, "fuzzy", "subsequence" or "substring"
+	smartCase       bool     // --smart-case: an uppercase letter makes the filter case-sensitive
+	manifest        bool     // --manifest: list the folders named in a .pf-dirs file
+	showHidden      []string // --show-hidden: dot-folder name patterns listed anyway
+	countSkipped    bool     // --count-skipped: note how many folders were left out
+	long            bool     // --long: show each folder's permissions and owner
+	envPath         bool     // --env-path: print the home folder as $HOME
+	tildePath       bool     // --tilde-path: print the home folder as ~
+	saveQuery       bool     // --save-query: keep the filter used to select for --last-query
+	lastQuery       bool     // --last-query: print the saved filter and exit
+	mounts          bool     // --mounts: mark folders that are mount points
+	linkTargets     bool     // --link-targets: show where symlinked folders point
+	keepStub        bool     // --keep-stub: the current folder's entry ignores the filter
+	noStub          bool     // --no-stub: leave out the current folder's entry
+	readTimeout     float64  // --read-timeout: seconds before a folder read is abandoned, 0 = never
+	timeout         float64  // --timeout: idle seconds before pf quits without selecting, 0 = never
+	cursorStart     string   // --cursor-start: where a fresh listing puts the cursor, "stub" or "first"
+	socket          string   // --socket: Unix socket the selected path is sent to
+	du              bool     // --du: show each folder's total size
+	stats           bool     // --stats: show the folder count, and size with --du, in the header
+	only            string   // --only: glob folder names must match to be listed
+	age             ageLimit // --modified-within, --older-than: list only folders of this age
+	wrapSiblings    bool     // --wrap-siblings: sibling keys wrap around at the ends
+
+	sources map[string]string // settings not set by a flag, by --print-config key, and where they came from
+}
+
+// Where a fresh listing puts the cursor, for --cursor-start.
+const (
+	cursorStub  = "stub"  // on the [. select this folder] entry
+	cursorFirst = "first" // on the first real folder
+)
+
+func checkMatcher(v string) error {
+	if !slices.Contains(matcherNames, v) {
+		return fmt.Errorf("unknown match algorithm: %s (use %s)", v, strings.Join(matcherNames, ", "))
+	}
+	return nil
+}
+
+func checkCursorStart(v string) error {
+	if v != cursorStub && v != cursorFirst {
+		return fmt.Errorf("unknown cursor start: %s (use %s, %s)", v, cursorStub, cursorFirst)
+	}
+	return nil
+}
+
+func parseArgs(args []string) (options, error) {
+	opts := options{tuiOutput: "stderr", output: "stdout", scrollMargin: 2, maxDepth: 4, headerStyle: "middle", rank: rankPosition, match: matcherFuzzy, cursorStart: cursorStub}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+
+		// next returns the flag's value, from "--flag=value" or the next argument
+		taken := false
+		next := func() (string, error) {
+			taken = true
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "--help", "-h":
+			opts.help = true
+		case "--install":
+			opts.install = true
+		case "--select-current":
+			opts.selectCurrent = true
+		case "--leaves":
+			opts.leaves = true
+		case "--repos":
+			opts.repos = true
+		case "--max-depth":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("invalid --max-depth value: %s", v)
+			}
+			opts.maxDepth = n
+		case "--depth":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return opts, fmt.Errorf("invalid --depth value: %s", v)
+			}
+			opts.depth = n
+		case "--show-parent":
+			opts.showParent = true
+		case "--tui":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.tuiOutput = v
+		case "--output":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.output = v
+		case "--sticky-filter":
+			opts.stickyFilter = true
+		case "--trailing-slash":
+			opts.trailingSlash = true
+			opts.noTrailingSlash = false
+		case "--no-trailing-slash":
+			opts.noTrailingSlash = true
+			opts.trailingSlash = false
+		case "--root":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.boundary = v
+		case "--times":
+			opts.showTimes = true
+		case "--no-sort":
+			opts.sort = sortNone
+		case "--hidden-only":
+			opts.hiddenOnly = true
+		case "--trash":
+			opts.trash = true
+		case "--allow-local-config":
+			opts.localConfig = true
+		case "--name-only":
+			opts.nameOnly = true
+		case "--no-ignore":
+			opts.noIgnore = true
+		case "--save-history":
+			opts.saveHistory = true
+		case "--symlink-loop":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if opts.symlinkLoop, err = parseLinkMode(v); err != nil {
+				return opts, err
+			}
+		case "--echo":
+			opts.echo = true
+		case "--auto-descend":
+			opts.autoDescend = true
+		case "--enter-selects":
+			opts.enterSelects = true
+		case "--debug":
+			opts.debug = true
+		case "--verbose", "-V":
+			opts.verbose = true
+		case "--print-config":
+			opts.printConfig = true
+		case "--query":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.query = v
+		case "--bottom":
+			opts.bottom = true
+		case "--alt-screen":
+			opts.altScreen = true
+		case "--animate":
+			opts.animate = true
+		case "--dry-run":
+			opts.dryRun = true
+		case "--list":
+			opts.list = true
+		case "--no-color":
+			opts.noColor = true
+		case "--header-style":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if v != "middle" && v != "left" {
+				return opts, fmt.Errorf("unknown header style: %s (use middle, left)", v)
+			}
+			opts.headerStyle = v
+		case "--path-filter":
+			opts.pathFilter = true
+		case "--append-to":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.appendTo = v
+		case "--multi":
+			opts.multi = true
+		case "--loop":
+			opts.loop = true
+		case "--preselect":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.preselect = v
+			opts.multi = true
+		case "--rank":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if v != rankPosition && v != rankScore {
+				return opts, fmt.Errorf("unknown rank mode: %s (use %s)", v, strings.Join(rankModeNames, ", "))
+			}
+			opts.rank = v
+		case "--smart-case":
+			opts.smartCase = true
+		case "--match":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if err := checkMatcher(v); err != nil {
+				return opts, err
+			}
+			opts.match = v
+		case "--manifest":
+			opts.manifest = true
+		case "--count-skipped":
+			opts.countSkipped = true
+		case "--env-path":
+			opts.envPath = true
+		case "--tilde-path":
+			opts.tildePath = true
+		case "--save-query":
+			opts.saveQuery = true
+		case "--last-query":
+			opts.lastQuery = true
+		case "--mounts":
+			opts.mounts = true
+		case "--link-targets":
+			opts.linkTargets = true
+		case "--keep-stub":
+			opts.keepStub = true
+		case "--no-stub":
+			opts.noStub = true
+		case "--read-timeout":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			secs, err := strconv.ParseFloat(v, 64)
+			if err != nil || secs <= 0 {
+				return opts, fmt.Errorf("invalid --read-timeout value: %s", v)
+			}
+			opts.readTimeout = secs
+		case "--timeout":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			secs, err := strconv.ParseFloat(v, 64)
+			if err != nil || secs <= 0 {
+				return opts, fmt.Errorf("invalid --timeout value: %s", v)
+			}
+			opts.timeout = secs
+		case "--cursor-start":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if err := checkCursorStart(v); err != nil {
+				return opts, err
+			}
+			opts.cursorStart = v
+		case "--socket":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.socket = v
+		case "--du":
+			opts.du = true
+		case "--only":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if _, err := filepath.Match(v, ""); err != nil {
+				return opts, fmt.Errorf("invalid --only pattern: %s", v)
+			}
+			opts.only = v
+		case "--show-hidden":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			patterns, err := parsePatterns(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid --show-hidden value: %v", err)
+			}
+			opts.showHidden = patterns
+		case "--wrap-siblings":
+			opts.wrapSiblings = true
+		case "--modified-within", "--older-than":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			d, err := parseAge(v)
+			if err != nil {
+				return opts, err
+			}
+			if name == "--older-than" {
+				opts.age.older = d
+			} else {
+				opts.age.within = d
+			}
+		case "--log-jumps":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			opts.logJumps = v
+		case "--stats":
+			opts.stats = true
+		case "--long":
+			opts.long = true
+		case "--mouse":
+			opts.mouse = true
+		case "--sort":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			if opts.sort, err = parseSortMode(v); err != nil {
+				return opts, err
+			}
+		case "--scroll-margin":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("invalid --scroll-margin value: %s", v)
+			}
+			opts.scrollMargin, opts.hasScrollMargin = n, true
+		case "--height":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return opts, fmt.Errorf("invalid --height value: %s", v)
+			}
+			opts.height = n
+		case "--max-results":
+			v, err := next()
+			if err != nil {
+				return opts, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("invalid --max-results value: %s", v)
+			}
+			opts.maxResults = n
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return opts, fmt.Errorf("unknown option: %s", arg)
+			}
+			if opts.start != "" {
+				return opts, fmt.Errorf("unexpected argument: %s", arg)
+			}
+			opts.start = arg
+		}
+		// A switch given "=false" mustn't quietly turn on
+		if hasValue && !taken && strings.HasPrefix(name, "-") {
+			return opts, fmt.Errorf("%s doesn't take a value", name)
+		}
+	}
+	// Size order is meaningless without sizes
+	if opts.sort == sortSize {
+		opts.du = true
+	}
+	return opts, nil
+}
+
+// parsePatterns splits a comma-separated list of folder name patterns, as
+// for filepath.Match, such as ".github,.config*".
+func parsePatterns(s string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("bad pattern %q", p)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openOutput resolves an output target given on the command line:
+// "stdout", "stderr", "tty" (the controlling terminal), "fd:N" for an
+// inherited file descriptor, or a file path, which is created or truncated.
+func openOutput(target string) (*os.File, error) {
+	switch target {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "tty":
+		if runtime.GOOS == "windows" {
+			return os.OpenFile("CONOUT$", os.O_WRONLY, 0)
+		}
+		return os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	}
+	if fd, ok := strings.CutPrefix(target, "fd:"); ok {
+		n, err := strconv.Atoi(fd)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid file descriptor: %s", target)
+		}
+		f := os.NewFile(uintptr(n), target)
+		if f == nil {
+			return nil, fmt.Errorf("invalid file descriptor: %s", target)
+		}
+		return f, nil
+	}
+	return os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+// formatResult applies the output flags to the selected path.
+func formatResult(path string, opts options) string {
+	sep := string(filepath.Separator)
+	if opts.nameOnly && !isRoot(path) {
+		// A root such as / or C:\ is printed as is
+		path = filepath.Base(path)
+	}
+	// Portable forms of paths in the home folder, for scripts and configs
+	switch {
+	case opts.envPath:
+		path = replaceHome(path, "$HOME")
+	case opts.tildePath:
+		path = abbreviateHome(path)
+	}
+	switch {
+	case opts.trailingSlash && !strings.HasSuffix(path, sep):
+		path += sep
+	case opts.noTrailingSlash && !isRoot(path):
+		path = strings.TrimRight(path, sep)
+	}
+	return path
+}
+
+// sendToSocket writes lines, each ending in a newline, to the Unix socket
+// at path and closes the connection, for tools that embed pf as a picker.
+func sendToSocket(path string, lines []string) error {
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write([]byte(strings.Join(lines, "\n") + "\n")); err != nil {
+		conn.Close()
+		return err
+	}
+	return conn.Close()
+}
+
+// appendSelection adds path as a line at the end of file, creating it if
+// needed, for --append-to. The file is locked for the write, as with
+// --log-jumps, so pf sessions finishing at the same time don't interleave
+// their lines.
+func appendSelection(file, path string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteString(path + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// logJump adds a line for path to the --log-jumps file: the time of the
+// selection in RFC 3339 format, a tab, and the path. The file is locked
+// for the write, so concurrent pf sessions can't interleave their lines.
+func logJump(file, path string, at time.Time) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteString(at.Format(time.RFC3339) + "\t" + path + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
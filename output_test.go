@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestAppendSelectionConcurrent(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "picked")
+	var want []string
+	var wg sync.WaitGroup
+	for i := range 8 {
+		path := fmt.Sprintf("/src/session-%d/%s", i, strings.Repeat("x", 2000))
+		for range 20 {
+			want = append(want, path)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				if err := appendSelection(file, path); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %d lines, want %d whole ones", len(got), len(want))
+	}
+}
+
+func TestLoopReportsOutputErrors(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha")
+	out, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	bad := filepath.Join(dir, "missing", "picked")
+	m := testModel(t, dir, "--loop", "--append-to", bad)
+	m.emit = func(paths []string) error { return printResult(out, m.opts, paths, nil) }
+	m = typeText(m, "alpha")
+	next, cmd := m.Update(key("tab"))
+	m = next.(model)
+	if cmd != nil {
+		if _, quit := cmd().(tea.QuitMsg); quit {
+			t.Fatal("the picker quit on an --append-to error")
+		}
+	}
+	if !strings.Contains(m.notice, "--append-to") {
+		t.Errorf("notice %q doesn't report the error", m.notice)
+	}
+	if entries, _ := m.errLog.list(); len(entries) != 1 {
+		t.Errorf("%d errors logged, want 1", len(entries))
+	}
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != filepath.Join(dir, "alpha") {
+		t.Errorf("printed %q, want the alpha path", got)
+	}
+}
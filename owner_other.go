@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileOwner is unavailable here, so --long shows only permissions.
+func fileOwner(info os.FileInfo) (owner, group string, ok bool) {
+	return "", "", false
+}
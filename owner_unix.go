@@ -0,0 +1,52 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ownerNames caches user and group names by ID, since a listing usually
+// has only a few owners. Folder reads can run in the background, hence
+// the lock.
+var ownerNames struct {
+	sync.Mutex
+	users, groups map[uint32]string
+}
+
+// fileOwner returns the names of the user and group owning the file
+// described by info, or their numeric IDs when they have no name.
+func fileOwner(info os.FileInfo) (owner, group string, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", false
+	}
+	ownerNames.Lock()
+	defer ownerNames.Unlock()
+	if ownerNames.users == nil {
+		ownerNames.users = make(map[uint32]string)
+		ownerNames.groups = make(map[uint32]string)
+	}
+	uid, gid := uint32(st.Uid), uint32(st.Gid)
+	owner, found := ownerNames.users[uid]
+	if !found {
+		owner = strconv.FormatUint(uint64(uid), 10)
+		if u, err := user.LookupId(owner); err == nil {
+			owner = u.Username
+		}
+		ownerNames.users[uid] = owner
+	}
+	group, found = ownerNames.groups[gid]
+	if !found {
+		group = strconv.FormatUint(uint64(gid), 10)
+		if g, err := user.LookupGroupId(group); err == nil {
+			group = g.Name
+		}
+		ownerNames.groups[gid] = group
+	}
+	return owner, group, true
+}
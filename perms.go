@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// readPerms records each folder's permissions and owner for --long, as
+// "drwxr-xr-x 0755 alice staff". Owners and groups are padded to the
+// widest in the listing, so the columns line up.
+func readPerms(items []item) {
+	type entry struct{ mode, owner, group string }
+	entries := make([]entry, len(items))
+	ownerWidth, groupWidth := 0, 0
+	for i := range items {
+		info, err := os.Stat(items[i].path)
+		if err != nil {
+			continue
+		}
+		e := entry{mode: permString(info.Mode())}
+		if owner, group, ok := fileOwner(info); ok {
+			e.owner, e.group = owner, group
+			ownerWidth = max(ownerWidth, utf8.RuneCountInString(owner))
+			groupWidth = max(groupWidth, utf8.RuneCountInString(group))
+		}
+		entries[i] = e
+	}
+	for i, e := range entries {
+		if e.mode == "" {
+			continue
+		}
+		items[i].perms = e.mode
+		if ownerWidth > 0 {
+			items[i].perms += " " + padRight(e.owner, ownerWidth) + " " + padRight(e.group, groupWidth)
+		}
+	}
+}
+
+// permString formats mode as ls does, followed by the permission bits in
+// octal: "drwxr-xr-x 0755", or "drwxrwxrwt 1777" with the sticky bit.
+func permString(mode fs.FileMode) string {
+	const rwx = "rwxrwxrwx"
+	b := []byte("----------")
+	if mode.IsDir() {
+		b[0] = 'd'
+	}
+	for i := range 9 {
+		if mode&(1<<(8-i)) != 0 {
+			b[i+1] = rwx[i]
+		}
+	}
+	octal := uint32(mode.Perm())
+	special := func(set fs.FileMode, pos int, bit uint32, on, off byte) {
+		if mode&set == 0 {
+			return
+		}
+		octal |= bit
+		if b[pos] == '-' {
+			b[pos] = off
+		} else {
+			b[pos] = on
+		}
+	}
+	special(fs.ModeSetuid, 3, 04000, 's', 'S')
+	special(fs.ModeSetgid, 6, 02000, 's', 'S')
+	special(fs.ModeSticky, 9, 01000, 't', 'T')
+	return fmt.Sprintf("%s %04o", b, octal)
+}
+
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-utf8.RuneCountInString(s)))
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"regexp"
+)
+
+// styleSeq matches the escape sequences pf colors and highlights text with.
+var styleSeq = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// stripStyles removes all styling from s, for --no-color. The cursor (>),
+// marks (+) and other markers are text, so the plain layout still shows
+// everything.
+func stripStyles(s string) string {
+	return styleSeq.ReplaceAllString(s, "")
+}
+
+// plainTerminal reports whether the environment asks for plain text: NO_COLOR
+// is set (see no-color.org), or the terminal is a dumb one that would
+// print escape sequences literally.
+func plainTerminal() bool {
+	return os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb"
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// protectedPath returns the location of the protected folders list, or ""
+// when there is no config directory.
+func protectedPath() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "protected")
+}
+
+// loadProtected reads the protected folders list: one folder per line, as
+// an absolute path or one starting with ~/, with blank lines and #
+// comments skipped. Rename, archive and delete refuse to act on a listed
+// folder or anything below it. A missing file means none are listed.
+func loadProtected(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var dirs []string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) && line != "~" && !strings.HasPrefix(line, "~/") {
+			return nil, fmt.Errorf("%s:%d: %s must be an absolute path or start with ~/", path, lineNo, line)
+		}
+		dir := expandPath(line)
+		dirs = append(dirs, dir)
+		// Cover the folder when it's reached through a symlink, too
+		if real, err := filepath.EvalSymlinks(dir); err == nil && real != dir {
+			dirs = append(dirs, real)
+		}
+	}
+	return dirs, scanner.Err()
+}
+
+// isProtected reports whether path may not be renamed, archived or
+// deleted: it is the home folder or the filesystem root, or it is at or
+// below a folder in the protected list. The home folder protects only
+// itself, or nothing below it could be changed.
+func (m model) isProtected(path string) bool {
+	paths := []string{path}
+	if real, err := filepath.EvalSymlinks(path); err == nil && real != path {
+		paths = append(paths, real)
+	}
+	home, _ := os.UserHomeDir()
+	for _, p := range paths {
+		if isRoot(p) || (home != "" && p == home) {
+			return true
+		}
+		for _, dir := range m.protected {
+			if isWithin(p, dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// protectedNotice explains, for the status line, why a key did nothing.
+func protectedNotice(path string) string {
+	return "\033[90mprotected: " + abbreviateHome(path) + "\033[0m"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// revealMsg reports the outcome of opening a folder in the file manager.
+type revealMsg struct {
+	path string
+	err  error
+}
+
+// fileManagers lists the commands that open a folder in the native file
+// manager, in order of preference for the current platform.
+func fileManagers() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"open"}}
+	case "windows":
+		return [][]string{{"explorer"}}
+	}
+	return [][]string{{"xdg-open"}, {"gio", "open"}, {"kde-open"}}
+}
+
+// revealFolder opens path in the file manager without waiting for it, so
+// pf keeps running.
+func revealFolder(path string) tea.Cmd {
+	return func() tea.Msg {
+		for _, args := range fileManagers() {
+			if _, err := exec.LookPath(args[0]); err != nil {
+				continue
+			}
+			cmd := exec.Command(args[0], append(args[1:], path)...)
+			if err := cmd.Start(); err != nil {
+				return revealMsg{path: path, err: err}
+			}
+			// Reap the process whenever it exits
+			go cmd.Wait()
+			return revealMsg{path: path}
+		}
+		return revealMsg{path: path, err: errors.New("no file manager command found")}
+	}
+}
+
+// revealNotice describes msg for the status line.
+func revealNotice(msg revealMsg) string {
+	if msg.err != nil {
+		return "\033[31mCouldn't open " + filepath.Base(msg.path) + ": " + msg.err.Error() + "\033[0m"
+	}
+	return "\033[90mOpened " + abbreviateHome(msg.path) + " in the file manager\033[0m"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shownPath returns the location of the list of dot-folders to show, or ""
+// when there is no config directory.
+func shownPath() string {
+	dir := configDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "show-hidden")
+}
+
+// loadShown reads the dot-folders to list wherever they are: one name or
+// glob per line, as --show-hidden takes them, with blank lines and #
+// comments skipped. A missing file means none are listed.
+func loadShown(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := filepath.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: bad pattern %q", path, lineNo, line)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// addShown adds the patterns from the show-hidden file to those given with
+// --show-hidden or PF_SHOW_HIDDEN.
+func addShown(opts *options) error {
+	shown, err := loadShown(shownPath())
+	if err != nil {
+		return err
+	}
+	if len(shown) > 0 {
+		opts.showHidden = append(opts.showHidden, shown...)
+		opts.setSource("show-hidden", shownPath())
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// slotCount is how many search slots there are, numbered from 1.
+const slotCount = 9
+
+// searches holds filters saved with the save-slot key, so common
+// searches can be switched between quickly. An empty slot is "".
+type searches [slotCount]string
+
+// slotsPath returns the file --save-history keeps the slots in, or ""
+// when there is no state directory.
+func slotsPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "search-slots")
+}
+
+// loadSlots reads saved slots from path, one per line in slot order. A
+// missing or unreadable file just means empty slots.
+func loadSlots(path string) searches {
+	var s searches
+	if path == "" {
+		return s
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if i == slotCount {
+			break
+		}
+		s[i] = line
+	}
+	return s
+}
+
+// save writes the slots to path, one per line, with blank lines for the
+// empty ones.
+func (s searches) save(path string) error {
+	if path == "" {
+		return errNoDataDir
+	}
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(s[:], "\n")+"\n"), 0600)
+}
+
+// slotKey returns the slot index for a key from 1 to 9.
+func slotKey(k string) (int, bool) {
+	if len(k) != 1 || k[0] < '1' || k[0] > '0'+slotCount {
+		return 0, false
+	}
+	return int(k[0] - '1'), true
+}
+
+// saveSlot handles the key pressed after save-slot: a digit saves the
+// filter to that slot, or empties it when there is no filter, and any
+// other key cancels.
+func (m model) saveSlot(k string) model {
+	m.savingSlot = false
+	i, ok := slotKey(k)
+	if !ok {
+		return m
+	}
+	m.slots[i] = m.filter
+	m.notice = fmt.Sprintf("\033[90msaved to slot %d\033[0m", i+1)
+	if m.filter == "" {
+		m.notice = fmt.Sprintf("\033[90memptied slot %d\033[0m", i+1)
+	}
+	if m.opts.saveHistory {
+		// The slot still works for this session
+		if err := m.slots.save(slotsPath()); err != nil {
+			m.errLog.add(slotsPath(), err)
+			m.notice = fmt.Sprintf("\033[31mslot %d kept for now, but couldn't be saved: %s\033[0m", i+1, err)
+		}
+	}
+	return m
+}
+
+// updateSlots handles keys while the search slots overlay is open.
+func (m model) updateSlots(k string) model {
+	if i, ok := slotKey(k); ok {
+		if m.slots[i] != "" {
+			m.showSlots = false
+			m.history.reset()
+			m.recallFilter(m.slots[i], true)
+		}
+		return m
+	}
+	if k == "esc" || m.keys.action(k) == actSlots {
+		m.showSlots = false
+	}
+	return m
+}
+
+func (m model) slotsView() string {
+	var lines []string
+	lines = append(lines, "")
+	lines = append(lines, "  \033[1;34mSaved filters\033[0m")
+	lines = append(lines, "")
+	for i, filter := range m.slots {
+		if filter == "" {
+			lines = append(lines, fmt.Sprintf("  \033[90m%d  (empty)\033[0m", i+1))
+		} else {
+			lines = append(lines, fmt.Sprintf("  \033[1m%d\033[0m  %s", i+1, filter))
+		}
+	}
+	lines = append(lines, "")
+	lines = append(lines, "  \033[90m1-9 recall • "+m.keys.label(actSaveSlot)+" then 1-9 saves the filter • Esc or "+m.keys.label(actSlots)+" close\033[0m")
+	lines = append(lines, "")
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortMode controls the order of folders in the listing.
+type sortMode int
+
+const (
+	sortName     sortMode = iota // alphabetical, case-insensitive
+	sortNatural                  // alphabetical with embedded numbers compared by value
+	sortModified                 // most recently modified first
+	sortNone                     // filesystem order, as read from disk
+	sortSize                     // largest first, with --du
+)
+
+var sortModeNames = []string{"name", "natural", "modified", "none", "size"}
+
+func (s sortMode) String() string { return sortModeNames[s] }
+
+// next returns the mode that follows s in the Ctrl+S cycle.
+func (s sortMode) next() sortMode { return (s + 1) % sortMode(len(sortModeNames)) }
+
+func parseSortMode(name string) (sortMode, error) {
+	for i, n := range sortModeNames {
+		if n == name {
+			return sortMode(i), nil
+		}
+	}
+	return sortName, fmt.Errorf("unknown sort mode: %s (use %s)", name, strings.Join(sortModeNames, ", "))
+}
+
+// lessFunc returns the name comparison for mode. Modified order can't be
+// decided from names alone, so it falls back to alphabetical.
+func lessFunc(mode sortMode) func(a, b string) bool {
+	if mode == sortNatural {
+		return lessNatural
+	}
+	return lessName
+}
+
+// sortDirs orders folder names in place according to mode. In none mode
+// the names keep their filesystem order.
+func sortDirs(dirs []string, mode sortMode) {
+	if mode == sortNone {
+		return
+	}
+	less := lessFunc(mode)
+	sort.Slice(dirs, func(i, j int) bool { return less(dirs[i], dirs[j]) })
+}
+
+// sortEntries orders entries in place according to mode.
+func sortEntries(entries []item, mode sortMode) {
+	if mode == sortNone {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].order < entries[j].order })
+		return
+	}
+	if mode == sortSize {
+		// Folders not measured yet go last
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			if (a.size == nil) != (b.size == nil) {
+				return a.size != nil
+			}
+			if a.size != nil && a.size.bytes != b.size.bytes {
+				return a.size.bytes > b.size.bytes
+			}
+			return lessName(a.name, b.name)
+		})
+		return
+	}
+	if mode == sortModified {
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := entries[i], entries[j]
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.After(b.modTime)
+			}
+			return lessName(a.name, b.name)
+		})
+		return
+	}
+	less := lessFunc(mode)
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i].name, entries[j].name) })
+}
+
+// lessName compares case-insensitively, so "apple" comes before "Zebra".
+func lessName(a, b string) bool {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	if la != lb {
+		return la < lb
+	}
+	return a < b
+}
+
+// lessNatural compares digit runs by numeric value, so "item2" comes
+// before "item10". Other runs compare case-insensitively.
+func lessNatural(a, b string) bool {
+	ra, rb := splitRuns(strings.ToLower(a)), splitRuns(strings.ToLower(b))
+	for i := 0; i < len(ra) && i < len(rb); i++ {
+		x, y := ra[i], rb[i]
+		if x == y {
+			continue
+		}
+		if isDigit(x[0]) && isDigit(y[0]) {
+			// Compare by value: ignore leading zeros, then fewer digits is smaller
+			tx, ty := strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			if len(tx) != len(ty) {
+				return len(tx) < len(ty)
+			}
+			if tx != ty {
+				return tx < ty
+			}
+			// Same value: fewer leading zeros first
+			return len(x) < len(y)
+		}
+		return x < y
+	}
+	if len(ra) != len(rb) {
+		return len(ra) < len(rb)
+	}
+	return a < b
+}
+
+// splitRuns splits s into alternating runs of digits and non-digits.
+func splitRuns(s string) []string {
+	var runs []string
+	start := 0
+	for i := 1; i <= len(s); i++ {
+		if i == len(s) || isDigit(s[i]) != isDigit(s[start]) {
+			runs = append(runs, s[start:i])
+			start = i
+		}
+	}
+	return runs
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
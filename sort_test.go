@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSortKeepsStubFirst(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha", "Beta", "item2", "item10", "zeta")
+	// Make modified order differ from name order
+	for i, name := range []string{"zeta", "alpha", "item10", "Beta", "item2"} {
+		when := time.Now().Add(-time.Duration(i) * time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, name), when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{nil, {"--show-parent"}, {"--du"}} {
+		m := testModel(t, dir, args...)
+		for range 2 * len(sortModeNames) {
+			for range 2 {
+				filtered := m.filtered()
+				if filtered[0].path != dir {
+					t.Errorf("%v sort %s reverse %v: %s listed first", args, m.sort, m.reverse, filtered[0].name)
+				}
+				for i, it := range filtered[:m.pinned] {
+					if !m.isPinned(it.path) {
+						t.Errorf("%v sort %s reverse %v: %s at %d among the pinned entries", args, m.sort, m.reverse, it.name, i)
+					}
+				}
+				m = press(m, "ctrl+r")
+			}
+			m = press(m, "ctrl+s")
+		}
+	}
+}
+
+func TestSiblingsFollowSort(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "a", "b", "c")
+	// Modified order: b, c, a
+	for i, name := range []string{"b", "c", "a"} {
+		when := time.Now().Add(-time.Duration(i) * time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, name), when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		sort, start, key string
+		reverse          bool
+		want             string
+	}{
+		{"name", "a", "alt+right", false, "b"},
+		{"name", "c", "alt+left", false, "b"},
+		{"modified", "c", "alt+left", false, "b"},
+		{"modified", "c", "alt+right", false, "a"},
+		{"modified", "c", "alt+right", true, "b"},
+		{"modified", "b", "alt+left", true, "c"},
+	} {
+		m := testModel(t, filepath.Join(dir, tt.start), "--sort", tt.sort)
+		if tt.reverse {
+			m = press(m, "ctrl+r")
+		}
+		m = press(m, tt.key)
+		if got := filepath.Base(m.root); got != tt.want {
+			t.Errorf("%s (reversed %v) %s from %s went to %s, want %s", tt.sort, tt.reverse, tt.key, tt.start, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// spinnerMsg advances the spinner shown while background work runs.
+type spinnerMsg struct{}
+
+func spinnerTick() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg { return spinnerMsg{} })
+}
+
+// startSpinner begins ticking unless a tick loop is already running.
+func (m *model) startSpinner() tea.Cmd {
+	if m.spinning {
+		return nil
+	}
+	m.spinning = true
+	return spinnerTick()
+}
+
+// busy reports whether background work is in flight.
+func (m model) busy() bool {
+	return m.walking || m.readPending || m.sizing
+}
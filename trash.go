@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// trashDir returns the user's trash folder for --trash: ~/.Trash on macOS,
+// and the XDG home trash ($XDG_DATA_HOME/Trash, falling back to
+// ~/.local/share/Trash) elsewhere on Unix. It returns "" where pf can't
+// trash folders, in which case delete removes them permanently.
+func trashDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, ".Trash")
+	case "windows", "plan9":
+		return ""
+	}
+	if base := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(base) {
+		return filepath.Join(base, "Trash")
+	}
+	return filepath.Join(home, ".local", "share", "Trash")
+}
+
+// moveToTrash moves path into the trash. On XDG systems a matching
+// .trashinfo file records where it came from, so file managers can
+// restore it. A folder on another filesystem than the home trash goes to
+// that filesystem's own trash, as the freedesktop.org spec has it, since
+// it can't be moved home without copying.
+func moveToTrash(path string) error {
+	dir := trashDir()
+	if dir == "" {
+		return fmt.Errorf("no trash available")
+	}
+	topdir := ""
+	if !sameDevice(path, dir) {
+		if runtime.GOOS == "darwin" {
+			return fmt.Errorf("%s is on another volume than the trash; delete it without --trash", path)
+		}
+		topdir = mountPoint(path)
+		dir = topdirTrash(topdir)
+	}
+	if runtime.GOOS == "darwin" {
+		return trashError(path, os.Rename(path, uniqueName(dir, filepath.Base(path))))
+	}
+
+	files := filepath.Join(dir, "files")
+	info := filepath.Join(dir, "info")
+	if err := os.MkdirAll(files, 0700); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(info, 0700); err != nil {
+		return err
+	}
+
+	// Claim a name by creating its .trashinfo first, as the spec asks
+	base := filepath.Base(path)
+	var infoFile *os.File
+	var name string
+	for i := 1; ; i++ {
+		name = base
+		if i > 1 {
+			name = base + " " + strconv.Itoa(i)
+		}
+		f, err := os.OpenFile(filepath.Join(info, name+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			infoFile = f
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+	}
+	infoPath := infoFile.Name()
+	// A filesystem's own trash records paths relative to its top
+	original := path
+	if topdir != "" {
+		if rel, err := filepath.Rel(topdir, path); err == nil {
+			original = rel
+		}
+	}
+	_, err := fmt.Fprintf(infoFile, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: original}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	if cerr := infoFile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(path, filepath.Join(files, name))
+	}
+	if err != nil {
+		os.Remove(infoPath)
+		return trashError(path, err)
+	}
+	return nil
+}
+
+// topdirTrash returns the trash on the filesystem mounted at topdir: the
+// shared $topdir/.Trash/$uid when an administrator has set up .Trash as a
+// sticky folder, and $topdir/.Trash-$uid otherwise.
+func topdirTrash(topdir string) string {
+	uid := strconv.Itoa(os.Getuid())
+	shared := filepath.Join(topdir, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		return filepath.Join(shared, uid)
+	}
+	return filepath.Join(topdir, ".Trash-"+uid)
+}
+
+// sameDevice reports whether path is on the filesystem that holds dir, or
+// would hold it once created. Where devices can't be told apart, it
+// assumes so.
+func sameDevice(path, dir string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return true
+	}
+	for {
+		dirInfo, err := os.Stat(dir)
+		if err == nil {
+			a, okA := deviceID(info)
+			b, okB := deviceID(dirInfo)
+			return !okA || !okB || a == b
+		}
+		if isRoot(dir) {
+			return true
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// mountPoint returns the top of the filesystem holding path: the highest
+// folder above it on the same device.
+func mountPoint(path string) string {
+	dir := filepath.Dir(path)
+	for !isRoot(dir) && sameDevice(dir, filepath.Dir(dir)) {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// trashError explains a failed move into the trash. Moving across
+// filesystems, say through a bind mount, can't work without copying.
+func trashError(path string, err error) error {
+	if crossDevice(err) {
+		return fmt.Errorf("can't move %s to the trash from another filesystem; delete it without --trash", path)
+	}
+	return err
+}
+
+// uniqueName returns dir/name, adding a number when that already exists.
+func uniqueName(dir, name string) string {
+	path := filepath.Join(dir, name)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, name+" "+strconv.Itoa(i))
+	}
+}
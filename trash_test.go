@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMoveToTrash(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checks the XDG trash layout")
+	}
+	data := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", data)
+	dir := t.TempDir()
+	mkdirs(t, dir, "old/inside")
+	if err := moveToTrash(filepath.Join(dir, "old")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(data, "Trash", "files", "old", "inside")); err != nil {
+		t.Errorf("folder not in the trash: %v", err)
+	}
+	info, err := os.ReadFile(filepath.Join(data, "Trash", "info", "old.trashinfo"))
+	if err != nil || !strings.Contains(string(info), "Path="+filepath.Join(dir, "old")+"\n") {
+		t.Errorf("trashinfo %q, %v", info, err)
+	}
+}
+
+func TestMoveToTrashOtherFilesystem(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checks the XDG trash layout")
+	}
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	// /dev/shm is usually a tmpfs of its own
+	other, err := os.MkdirTemp("/dev/shm", "pf-trash-test")
+	if err != nil {
+		t.Skip("no /dev/shm")
+	}
+	defer os.RemoveAll(other)
+	if sameDevice(other, t.TempDir()) {
+		t.Skip("/dev/shm is on the same filesystem as the temp folder")
+	}
+	top := mountPoint(other)
+	trash := filepath.Join(top, ".Trash-"+strconv.Itoa(os.Getuid()))
+	name := filepath.Base(other)
+	// Remove the trash folders too, unless they were there already
+	for _, dir := range []string{trash, filepath.Join(trash, "files"), filepath.Join(trash, "info")} {
+		defer os.Remove(dir)
+	}
+	defer os.RemoveAll(filepath.Join(trash, "files", name))
+	defer os.Remove(filepath.Join(trash, "info", name+".trashinfo"))
+
+	if err := moveToTrash(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(trash, "files", name)); err != nil {
+		t.Errorf("folder not in %s: %v", trash, err)
+	}
+	info, err := os.ReadFile(filepath.Join(trash, "info", name+".trashinfo"))
+	if err != nil || !strings.Contains(string(info), "Path="+name+"\n") {
+		t.Errorf("trashinfo %q, %v; want a path relative to %s", info, err, top)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// terminalInput returns where the picker reads keys from: stdin when it is
+// a terminal, and otherwise the controlling terminal, so pf still works
+// with stdin redirected. ok is false when there is no terminal at all, as
+// in some CI jobs and editor task runners.
+func terminalInput() (in *os.File, ok bool) {
+	if isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return os.Stdin, true
+	}
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// finishLoading waits for a running walk, or a --read-timeout read, and
+// adds all its results, for answering queries without the picker. A read
+// gets until its timeout, as in the picker, and then fails.
+func (m *model) finishLoading() error {
+	if m.readPending {
+		m.readPending = false
+		l, ok := m.reading.poll(time.Until(m.reading.deadline))
+		if !ok {
+			m.readTimedOut = true
+			err := timeoutError(seconds(m.opts.readTimeout))
+			m.errLog.add(m.reading.path, err)
+			return fmt.Errorf("reading %s: %w", m.reading.path, err)
+		}
+		m.setListing(l)
+	}
+	if !m.walking {
+		return nil
+	}
+	for it := range m.walker.results {
+		m.entries = append(m.entries, it)
+	}
+	m.walking = false
+	m.resort()
+	return nil
+}
+
+// onlyMatch returns the single folder matching --query, waiting for a
+// walk or a slow read to finish first. It is used without a terminal,
+// when the picker can't be shown. Without a query there's nothing to
+// wait for.
+func (m *model) onlyMatch() (string, bool) {
+	if m.opts.query == "" || m.finishLoading() != nil {
+		return "", false
+	}
+	var found []string
+	for _, it := range m.matched {
+		if !m.isPinned(it.path) {
+			found = append(found, it.path)
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
+
+// listMatches prints every folder matching the filter, best first, one
+// per line, for --list. It returns 1 when nothing matches.
+func (m *model) listMatches() int {
+	if err := m.finishLoading(); err != nil {
+		fmt.Fprintln(os.Stderr, "pf: list: "+err.Error())
+		return 1
+	}
+	found := 0
+	for _, it := range m.filtered() {
+		if m.isPinned(it.path) {
+			continue
+		}
+		fmt.Println(formatResult(it.path, m.opts))
+		found++
+	}
+	if found == 0 {
+		if strings.TrimSpace(m.filter) != "" {
+			fmt.Fprintf(os.Stderr, "pf: list: no folders match '%s'\n", m.filter)
+		}
+		return 1
+	}
+	return 0
+}
+
+// Exit codes for --dry-run.
+const (
+	dryRunFound     = 0 // exactly one candidate (or no query)
+	dryRunNone      = 1 // the query matches nothing
+	dryRunAmbiguous = 3 // several candidates; the best one was printed
+)
+
+// dryRun prints the folder the picker would start on for --query, as Tab
+// would select it, and reports the number of candidates on stderr.
+func (m *model) dryRun() int {
+	if err := m.finishLoading(); err != nil {
+		fmt.Fprintln(os.Stderr, "pf: dry run: "+err.Error())
+		return dryRunNone
+	}
+	m.cursor = m.bestMatch()
+	n := m.matchCount()
+	if n == 0 && strings.TrimSpace(m.filter) != "" {
+		fmt.Fprintf(os.Stderr, "pf: dry run: no candidates for '%s'\n", m.filter)
+		return dryRunNone
+	}
+	// With --no-stub an empty folder has nothing to select
+	filtered := m.filtered()
+	if len(filtered) == 0 {
+		fmt.Fprintln(os.Stderr, "pf: dry run: no folders to select")
+		return dryRunNone
+	}
+	fmt.Println(formatResult(filtered[m.cursor].path, m.opts))
+	if n == 1 {
+		fmt.Fprintln(os.Stderr, "pf: dry run: 1 candidate")
+		return dryRunFound
+	}
+	fmt.Fprintf(os.Stderr, "pf: dry run: %d candidates\n", n)
+	if strings.TrimSpace(m.filter) == "" {
+		return dryRunFound
+	}
+	fmt.Fprintln(os.Stderr, "pf: dry run: the query is ambiguous; printed the best match")
+	return dryRunAmbiguous
+}
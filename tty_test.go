@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pendingRead stands in for a --read-timeout read of dir still running
+// when the picker would have started.
+func pendingRead(m model, dir string) model {
+	m.entries = m.entries[:m.pinned] // as reload leaves them
+	m.rematch()
+	m.reading = startDirRead(m.reading.id+1, dir, m.listFilter(), m.errLog, m.opts, time.Second)
+	m.readPending = true
+	return m
+}
+
+func TestFinishLoadingWaitsForRead(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha", "beta")
+	m := pendingRead(testModel(t, dir, "--query", "bet"), dir)
+	if err := m.finishLoading(); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(m); !slices.Equal(got, []string{"beta"}) {
+		t.Errorf("listed %v after the read, want beta", got)
+	}
+	if m.readPending {
+		t.Error("read still pending")
+	}
+	path, ok := m.onlyMatch()
+	if !ok || path != filepath.Join(dir, "beta") {
+		t.Errorf("onlyMatch = %s, %v", path, ok)
+	}
+}
+
+func TestFinishLoadingTimesOut(t *testing.T) {
+	dir := t.TempDir()
+	m := testModel(t, dir, "--read-timeout", "0.05")
+	// A read that never answers, as on a hung network mount
+	m.reading = dirRead{id: m.reading.id + 1, path: dir, done: make(chan listing), deadline: time.Now().Add(50 * time.Millisecond)}
+	m.readPending = true
+	err := m.finishLoading()
+	var timeout timeoutError
+	if !errors.As(err, &timeout) || !strings.Contains(err.Error(), dir) {
+		t.Errorf("error %v, want a timeout reading %s", err, dir)
+	}
+	if !m.readTimedOut {
+		t.Error("the read isn't marked as timed out")
+	}
+}
+
+func TestOnlyMatchWithoutQueryDoesntWait(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "alpha")
+	m := testModel(t, dir, "--read-timeout", "10")
+	// A read that never answers, which a query would wait on
+	m.reading = dirRead{id: m.reading.id + 1, path: dir, done: make(chan listing), deadline: time.Now().Add(10 * time.Second)}
+	m.readPending = true
+	start := time.Now()
+	if path, ok := m.onlyMatch(); ok {
+		t.Errorf("onlyMatch without a query found %s", path)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("onlyMatch without a query waited %v for the read", waited)
+	}
+}
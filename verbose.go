@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// vlog is the --verbose log. It stays nil unless --verbose is given, so
+// logging costs a nil check when it's off.
+var vlog *log.Logger
+
+// openVerboseLog starts --verbose logging. The log is appended to pf.log
+// in pf's cache directory rather than written to stderr, where the
+// interface is drawn.
+func openVerboseLog() error {
+	dir := cacheDir()
+	if err := ensureDir(dir); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "pf.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	vlog = log.New(f, fmt.Sprintf("pf[%d] ", os.Getpid()), log.LstdFlags|log.Lmicroseconds)
+	return nil
+}
+
+// logf writes a line to the --verbose log as an event name followed by
+// key=value fields, such as logf("load", "path=%q", root).
+func logf(event, format string, args ...any) {
+	if vlog == nil {
+		return
+	}
+	vlog.Printf(event+" "+format, args...)
+}
+
+// since formats the time taken since start for the log.
+func since(start time.Time) string {
+	return time.Since(start).Round(time.Microsecond).String()
+}
+
+// logChanges records the navigation and filter edits an update made.
+func logChanges(before, after model) {
+	if after.root != before.root {
+		logf("navigate", "from=%q to=%q", before.root, after.root)
+	}
+	if after.filter != before.filter {
+		logf("filter", "query=%q matches=%d", after.filter, len(after.filtered()))
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// walkBatchSize caps how many results one walkMsg carries, so the list
+// fills in steadily instead of all at once.
+const walkBatchSize = 64
+
+// walkMsg delivers folders found by a background walk.
+type walkMsg struct {
+	id    int    // walk that produced the batch; stale walks are ignored
+	items []item // folders found since the previous batch
+	done  bool   // the walk has finished
+}
+
+// linkMode controls how walks treat symlinked folders.
+type linkMode int
+
+const (
+	linkFollowOnce linkMode = iota // follow, but never visit a real folder twice
+	linkSkip                       // leave symlinked folders out
+	linkError                      // follow once, and report symlink loops
+)
+
+var linkModeNames = []string{"follow-once", "skip", "error"}
+
+func (l linkMode) String() string { return linkModeNames[l] }
+
+func parseLinkMode(name string) (linkMode, error) {
+	for i, n := range linkModeNames {
+		if n == name {
+			return linkMode(i), nil
+		}
+	}
+	return linkFollowOnce, fmt.Errorf("unknown symlink loop mode: %s (use %s)", name, strings.Join(linkModeNames, ", "))
+}
+
+// walker streams results from a background directory walk.
+type walker struct {
+	id      int
+	results chan item
+	cancel  chan struct{}
+	scanned *atomic.Int64 // folders read so far
+	started time.Time
+	found   *int // results sent so far, used as their load order
+	filter  dirFilter
+	links   linkMode
+	visited map[string]bool         // real paths walked so far; walking goroutine only
+	loop    *atomic.Pointer[string] // first symlink loop found, with linkError
+	log     *errorLog
+	repos   bool // report git repositories instead of leaf folders
+	every   bool // report every folder, not just the leaves
+	depth   int  // with repos or every, how many levels down to look, 0 = no limit
+}
+
+// startLeafWalk walks root in the background and reports every leaf
+// folder (one without visible subfolders) by its path relative to root.
+func startLeafWalk(id int, root string, mode sortMode, filter dirFilter, links linkMode, log *errorLog) walker {
+	w := newWalker(id, filter, links, log)
+	go w.run(root, mode)
+	return w
+}
+
+// startRepoWalk walks root in the background and reports every git
+// repository (a folder containing .git) by its path relative to root,
+// without looking inside the repositories it finds. maxDepth limits how
+// many levels below root it looks, 0 for no limit.
+func startRepoWalk(id int, root string, mode sortMode, filter dirFilter, links linkMode, log *errorLog, maxDepth int) walker {
+	w := newWalker(id, filter, links, log)
+	w.repos = true
+	w.depth = maxDepth
+	go w.run(root, mode)
+	return w
+}
+
+// startTreeWalk walks root in the background and reports every folder
+// below it, down to maxDepth levels, by its path relative to root.
+func startTreeWalk(id int, root string, mode sortMode, filter dirFilter, links linkMode, log *errorLog, maxDepth int) walker {
+	w := newWalker(id, filter, links, log)
+	w.every = true
+	w.depth = maxDepth
+	go w.run(root, mode)
+	return w
+}
+
+func newWalker(id int, filter dirFilter, links linkMode, log *errorLog) walker {
+	return walker{
+		id:      id,
+		results: make(chan item),
+		cancel:  make(chan struct{}),
+		scanned: new(atomic.Int64),
+		started: time.Now(),
+		found:   new(int),
+		filter:  filter,
+		links:   links,
+		visited: make(map[string]bool),
+		loop:    new(atomic.Pointer[string]),
+		log:     log,
+	}
+}
+
+// run walks root, closing the results when done. It runs on the walking
+// goroutine.
+func (w walker) run(root string, mode sortMode) {
+	defer close(w.results)
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		real = root
+	}
+	w.walkTree(root, real, "", mode)
+}
+
+// walkTree descends into dir, whose symlinks resolve to real, and
+// returns false once the walk is cancelled.
+func (w walker) walkTree(dir, real, rel string, mode sortMode) bool {
+	// Reaching a folder a second time through a symlink adds nothing new
+	if w.visited[real] {
+		return true
+	}
+	w.visited[real] = true
+
+	// A repository is reported whole; the start folder's own .git doesn't
+	// count, so a monorepo's nested repositories can still be found
+	if w.repos && rel != "" {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			w.scanned.Add(1)
+			return w.send(item{name: rel, path: dir})
+		}
+		if w.atDepth(rel) {
+			w.scanned.Add(1)
+			return true
+		}
+	}
+	if w.every && rel != "" {
+		if !w.send(item{name: rel, path: dir}) {
+			return false
+		}
+		if w.atDepth(rel) {
+			w.scanned.Add(1)
+			return true
+		}
+	}
+
+	// Unreadable folders list as empty, so the walk carries on past them
+	var subdirs []string
+	dirs, err := listDirs(dir, w.filter)
+	w.log.add(dir, err)
+	for _, e := range dirs {
+		subdirs = append(subdirs, e.Name())
+	}
+	w.scanned.Add(1)
+	if len(subdirs) == 0 {
+		if rel == "" || w.repos || w.every {
+			return true
+		}
+		return w.send(item{name: rel, path: dir})
+	}
+
+	sortDirs(subdirs, mode)
+	for _, name := range subdirs {
+		path := filepath.Join(dir, name)
+		subReal := filepath.Join(real, name)
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if w.links == linkSkip {
+				continue
+			}
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				w.log.add(path, err)
+				continue
+			}
+			// A link back to this folder or one above it is a loop
+			if w.links == linkError && isWithin(real, target) {
+				msg := path + " → " + target
+				w.loop.CompareAndSwap(nil, &msg)
+			}
+			subReal = target
+		}
+		if !w.walkTree(path, subReal, filepath.Join(rel, name), mode) {
+			return false
+		}
+	}
+	return true
+}
+
+// atDepth reports whether the folder at rel is as deep as the walk looks.
+func (w walker) atDepth(rel string) bool {
+	return w.depth > 0 && strings.Count(rel, string(filepath.Separator))+1 >= w.depth
+}
+
+// walkProgress is a snapshot of a walk's totals. The status line shows
+// snapshots taken on spinner ticks so the numbers don't flicker.
+type walkProgress struct {
+	scanned int64
+	elapsed time.Duration
+	loop    string // first symlink loop found, with --symlink-loop error
+}
+
+func (w walker) progress() walkProgress {
+	if w.scanned == nil {
+		return walkProgress{}
+	}
+	p := walkProgress{scanned: w.scanned.Load(), elapsed: time.Since(w.started)}
+	if loop := w.loop.Load(); loop != nil {
+		p.loop = *loop
+	}
+	return p
+}
+
+// send delivers a result, returning false if the walk was cancelled.
+// Only the walking goroutine calls it.
+func (w walker) send(it item) bool {
+	// The age window picks the folders listed; the walk still looks
+	// inside folders of any age
+	if w.filter.age.active() {
+		if info, err := os.Stat(it.path); err == nil {
+			it.modTime = info.ModTime()
+		} else {
+			w.log.add(it.path, err)
+		}
+		if !w.filter.age.allows(it.modTime, time.Now()) {
+			return true
+		}
+	}
+	it.order = *w.found
+	select {
+	case w.results <- it:
+		*w.found++
+		return true
+	case <-w.cancel:
+		return false
+	}
+}
+
+// next waits for the walk's next batch of results.
+func (w walker) next() tea.Cmd {
+	return func() tea.Msg {
+		it, ok := <-w.results
+		if !ok {
+			return walkMsg{id: w.id, done: true}
+		}
+		batch := []item{it}
+		for len(batch) < walkBatchSize {
+			select {
+			case it, ok := <-w.results:
+				if !ok {
+					return walkMsg{id: w.id, items: batch, done: true}
+				}
+				batch = append(batch, it)
+			default:
+				return walkMsg{id: w.id, items: batch}
+			}
+		}
+		return walkMsg{id: w.id, items: batch}
+	}
+}
+
+// stop cancels the walk if it is still running.
+func (w walker) stop() {
+	if w.cancel == nil {
+		return
+	}
+	select {
+	case <-w.cancel:
+	default:
+		close(w.cancel)
+	}
+}
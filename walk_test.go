@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// lockedDir creates dir/name with mode 000, which only root can read.
+func lockedDir(t *testing.T, dir, name string) string {
+	t.Helper()
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any folder")
+	}
+	path := filepath.Join(dir, name)
+	mkdirs(t, dir, name+"/inside")
+	if err := os.Chmod(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(path, 0755) })
+	return path
+}
+
+func TestReadDirPermissionDenied(t *testing.T) {
+	locked := lockedDir(t, t.TempDir(), "locked")
+	entries, err := readDir(locked)
+	if len(entries) != 0 || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("readDir = %d entries, %v; want none and a permission error", len(entries), err)
+	}
+}
+
+func TestListingShowsUnreadableFolder(t *testing.T) {
+	dir := t.TempDir()
+	locked := lockedDir(t, dir, "locked")
+	mkdirs(t, dir, "open")
+	m := testModel(t, dir)
+	if got := names(m); !slices.Equal(got, []string{"locked", "open"}) {
+		t.Errorf("listed %v, want locked and open", got)
+	}
+	m.changeDir(locked)
+	if !m.denied || len(names(m)) != 0 {
+		t.Errorf("opening a locked folder: denied %v, listed %v", m.denied, names(m))
+	}
+}
+
+func TestWalkSkipsUnreadableFolder(t *testing.T) {
+	dir := t.TempDir()
+	locked := lockedDir(t, dir, "locked")
+	mkdirs(t, dir, "open/inner")
+	for _, args := range [][]string{{"--leaves"}, {"--depth", "3"}, {"--repos"}} {
+		m := testModel(t, dir, args...)
+		m.finishLoading()
+		if args[0] != "--repos" && !slices.Contains(names(m), filepath.Join("open", "inner")) {
+			t.Errorf("%v: listed %v, want open/inner", args, names(m))
+		}
+		logged, _ := m.errLog.list()
+		if !slices.ContainsFunc(logged, func(e logEntry) bool { return e.path == locked }) {
+			t.Errorf("%v: %s not logged as unreadable", args, locked)
+		}
+	}
+}
+
+// finishWithin waits for m's walk like finishWalk, failing the test if it
+// runs past a few seconds.
+func finishWithin(t *testing.T, m model) model {
+	t.Helper()
+	done := make(chan model)
+	go func() {
+		m.finishLoading()
+		done <- m
+	}()
+	select {
+	case m = <-done:
+		return m
+	case <-time.After(5 * time.Second):
+		m.walker.stop()
+		t.Fatal("walk didn't finish")
+		return m
+	}
+}
+
+func TestWalkEndsAtSymlinkCycle(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "a/b", "c")
+	// a/b/up leads back to a, and c/a across to it
+	if err := os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "a", "b", "up")); err != nil {
+		t.Skip("can't create symlinks:", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "a"), filepath.Join(dir, "c", "a")); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range linkModeNames {
+		for _, walk := range [][]string{{"--leaves"}, {"--repos"}, {"--depth", "50"}} {
+			m := testModel(t, dir, append(walk, "--symlink-loop", mode)...)
+			m = finishWithin(t, m)
+			listed := names(m)
+			if walk[0] == "--depth" {
+				if slices.Contains(listed, filepath.Join("a", "b", "up", "b")) {
+					t.Errorf("%s %v: went round the cycle: %v", mode, walk, listed)
+				}
+				if slices.Contains(listed, filepath.Join("c", "a", "b")) {
+					t.Errorf("%s %v: listed a twice: %v", mode, walk, listed)
+				}
+				if mode == "skip" && slices.Contains(listed, filepath.Join("a", "b", "up")) {
+					t.Errorf("%s %v: followed a link: %v", mode, walk, listed)
+				}
+			}
+			if loop := m.walker.progress().loop; (mode == "error") != (loop != "") {
+				t.Errorf("%s %v: loop reported as %q", mode, walk, loop)
+			}
+		}
+	}
+}
+
+func TestWalkAgeWindow(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "old/new", "old/stale", "fresh", "repos/old/.git", "repos/new/.git")
+	stale := time.Now().Add(-60 * 24 * time.Hour)
+	for _, name := range []string{"old", "old/stale", "repos/old"} {
+		if err := os.Chtimes(filepath.Join(dir, name), stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"--leaves", "--modified-within", "7d"}, []string{"fresh", "old/new", "repos/new"}},
+		{[]string{"--leaves", "--older-than", "30d"}, []string{"old/stale", "repos/old"}},
+		{[]string{"--depth", "2", "--modified-within", "7d"}, []string{"fresh", "old/new", "repos", "repos/new"}},
+		{[]string{"--depth", "1", "--older-than", "30d"}, []string{"old"}},
+		{[]string{"--repos", "--modified-within", "7d"}, []string{"repos/new"}},
+	} {
+		m := finishWithin(t, testModel(t, dir, tt.args...))
+		var got []string
+		for _, name := range names(m) {
+			got = append(got, filepath.ToSlash(name))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%v listed %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRenameInWalkStaysInPlace(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "src/app", "docs")
+	m := finishWithin(t, testModel(t, dir, "--depth", "2"))
+	m = typeText(m, "app")
+	if got := filepath.Join(dir, cursorName(m)); got != filepath.Join(dir, "src", "app") {
+		t.Fatalf("cursor on %s, want src/app", got)
+	}
+	m = press(m, "ctrl+e")
+	m = typeText(m, "2")
+	m = press(m, "enter")
+	if m.renameMode {
+		t.Fatalf("still renaming: %s", m.renameError)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "src", "app2")); err != nil {
+		t.Errorf("src/app wasn't renamed in place: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app2")); err == nil {
+		t.Error("src/app was moved up to the start folder")
+	}
+	m.walker.stop()
+}
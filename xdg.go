@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// errNoDataDir is returned when neither an XDG variable nor the home
+// directory is available, so pf can't persist anything.
+var errNoDataDir = errors.New("no config or state directory available")
+
+// configDir returns pf's config directory: $XDG_CONFIG_HOME/pf, falling
+// back to ~/.config/pf. It returns "" when neither can be determined.
+func configDir() string {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// stateDir returns pf's state directory: $XDG_STATE_HOME/pf, falling back
+// to ~/.local/state/pf. It returns "" when neither can be determined.
+func stateDir() string {
+	return xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// cacheDir returns pf's cache directory: $XDG_CACHE_HOME/pf, falling back
+// to ~/.cache/pf. It returns "" when neither can be determined.
+func cacheDir() string {
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+func xdgDir(env, fallback string) string {
+	// The spec says relative values are invalid and should be ignored
+	if base := os.Getenv(env); filepath.IsAbs(base) {
+		return filepath.Join(base, "pf")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+	return filepath.Join(home, fallback, "pf")
+}
+
+// ensureDir creates dir, as returned by configDir, stateDir or cacheDir,
+// before a file is written into it.
+func ensureDir(dir string) error {
+	if dir == "" {
+		return errNoDataDir
+	}
+	return os.MkdirAll(dir, 0700)
+}